@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+)
+
+// firmwareAdvisory is a known defect of the drives whose model and firmware
+// match the given regular expressions.
+type firmwareAdvisory struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Model       string `json:"model"`
+	Firmware    string `json:"firmware"`
+	Description string `json:"description,omitempty"`
+
+	modelRgx    *regexp.Regexp
+	firmwareRgx *regexp.Regexp
+}
+
+type advisoryDB []*firmwareAdvisory
+
+var advisorySeverities = map[string]int{"info": 1, "warning": 2, "critical": 3}
+
+var defaultAdvisoryDB = advisoryDB{
+	{
+		ID:          "HPE-SAS-SSD-32768H",
+		Severity:    "critical",
+		Model:       `^(EK0800JVYPN|EO0400JDVFB|EO0800JDVFC|EO1600JVYPP|MK0800JVYPQ|MO0400JDVEU|MO0800JDVEV|MO1600JVYPR|MO3200JVYPT|VO0480JFDGT|VO0960JFDGU|VO1920JFDGV|VO3840JFDHA)$`,
+		Firmware:    `^HPD[1-7]$`,
+		Description: "fails permanently after 32,768 power-on hours, fixed in firmware HPD8",
+	},
+	{
+		ID:          "CRUCIAL-M4-5184H",
+		Severity:    "critical",
+		Model:       `^M4-CT\d+M4SSD\d$`,
+		Firmware:    `^000\d$`,
+		Description: "stops responding every hour after 5,184 power-on hours, fixed in firmware 0309",
+	},
+	{
+		ID:          "SEAGATE-7200.11-BSY",
+		Severity:    "critical",
+		Model:       `^ST3(160813|320613|500320|640323|750330|1000340|1500341)AS$`,
+		Firmware:    `^(SD1[5-9]|AD14)$`,
+		Description: "may become inaccessible after a power cycle, fixed in firmware SD1A",
+	},
+	{
+		ID:          "INTEL-320-8MB",
+		Severity:    "critical",
+		Model:       `^INTEL SSDSA[12]CW`,
+		Firmware:    `^4PC10302$`,
+		Description: "may report a capacity of 8MB and lose its data after a power loss, fixed in firmware 4PC10362",
+	},
+	{
+		ID:          "SAMSUNG-840EVO-READ",
+		Severity:    "warning",
+		Model:       `^Samsung SSD 840 EVO`,
+		Firmware:    `^EXT0[ABC]B`,
+		Description: "reading old data gets slower over time, fixed in firmware EXT0DB6Q",
+	},
+}
+
+func init() {
+	if err := defaultAdvisoryDB.compile(); err != nil {
+		panic(err)
+	}
+}
+
+func (db advisoryDB) compile() (err error) {
+	for _, advisory := range db {
+		if advisory.modelRgx, err = regexp.Compile(advisory.Model); err != nil {
+			return err
+		}
+		if advisory.firmwareRgx, err = regexp.Compile(advisory.Firmware); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAdvisoryDB reads additional advisories from a JSON file, in the same
+// format as defaultAdvisoryDB, to be appended to it.
+func loadAdvisoryDB(path string) (advisoryDB, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db := advisoryDB{}
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	return db, db.compile()
+}
+
+// match returns the advisories applying to the given model and firmware.
+func (db advisoryDB) match(model, firmware string) []*firmwareAdvisory {
+	matched := []*firmwareAdvisory{}
+	if model == "" || firmware == "" {
+		return matched
+	}
+	for _, advisory := range db {
+		if advisory.modelRgx.MatchString(model) && advisory.firmwareRgx.MatchString(firmware) {
+			matched = append(matched, advisory)
+		}
+	}
+	return matched
+}
+
+// highestSeverity returns the most severe of the given advisories' severities.
+func highestSeverity(advisories []*firmwareAdvisory) string {
+	severity := ""
+	for _, advisory := range advisories {
+		if advisorySeverities[advisory.Severity] > advisorySeverities[severity] {
+			severity = advisory.Severity
+		}
+	}
+	return severity
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdvisoryMatch(t *testing.T) {
+	matched := defaultAdvisoryDB.match("M4-CT256M4SSD2", "0009")
+	assert.Len(t, matched, 1)
+	assert.Equal(t, "CRUCIAL-M4-5184H", matched[0].ID)
+	assert.Equal(t, "critical", highestSeverity(matched))
+
+	assert.Empty(t, defaultAdvisoryDB.match("M4-CT256M4SSD2", "0309"))
+	assert.Empty(t, defaultAdvisoryDB.match("HGST HDN724040ALE640", "MJAOA5E0"))
+
+	info := &smartCtlInfo{Vendor: "HP", Product: "VO0960JFDGU", Revision: "HPD4"}
+	matched = defaultAdvisoryDB.match(info.modelAndFirmware())
+	assert.Len(t, matched, 1)
+	assert.Equal(t, "HPE-SAS-SSD-32768H", matched[0].ID)
+	info.Revision = "HPD8"
+	assert.Empty(t, defaultAdvisoryDB.match(info.modelAndFirmware()))
+}
+
+func TestLoadAdvisoryDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk-health-checker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "advisories.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`[
+		{"id": "LOCAL-HGST-512E", "severity": "warning", "model": "^HGST HDN7240", "firmware": "^MJAOA5E0$", "description": "misreports 512e alignment"},
+		{"id": "LOCAL-HGST-INFO", "severity": "info", "model": "^HGST", "firmware": "."}
+	]`), 0644))
+
+	extra, err := loadAdvisoryDB(path)
+	assert.NoError(t, err)
+	db := append(append(advisoryDB{}, defaultAdvisoryDB...), extra...)
+	matched := db.match("HGST HDN724040ALE640", "MJAOA5E0")
+	assert.Len(t, matched, 2)
+	assert.Equal(t, "LOCAL-HGST-512E", matched[0].ID)
+	assert.Equal(t, "warning", highestSeverity(matched))
+}
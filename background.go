@@ -0,0 +1,107 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// backgroundScan is the background medium scan status and results log of a
+// SCSI disk.
+type backgroundScan struct {
+	Status      string
+	Scans       int
+	MediumScans int
+	ProgressPct float64
+	HasProgress bool
+	Entries     []*backgroundScanEntry
+	// Unrecognised are the lines that are neither parsed nor headers
+	Unrecognised []string
+}
+
+// backgroundScanEntry is an LBA the background scan found an error at.
+type backgroundScanEntry struct {
+	PowerOnTime    string
+	LBA            uint64
+	SenseKey       int
+	ASC            int
+	ASCQ           int
+	ReassignStatus string
+}
+
+var (
+	backgroundScansRgx       = regexp.MustCompile(`^Number of background scans performed:\s*(\d+)(?:,\s*scan progress:\s*([\d.]+)%)?`)
+	backgroundMediumScansRgx = regexp.MustCompile(`^Number of background medium scans performed:\s*(\d+)`)
+	backgroundScanHeaderRgx  = regexp.MustCompile(`^(Background scan results log|Accumulated power on time|#\s+when\s+lba)`)
+	// smartctl writes the LBA as hex bytes and the sense key, ASC and ASCQ
+	// in hex without prefix, e.g. 1 25535:41  0000000007f5b8d5  [3,11,0]
+	backgroundScanEntryRgx = regexp.MustCompile(`^\d+\s+(\d+:\d+)\s+([0-9a-fA-F]+)\s+\[([0-9a-fA-F]+),([0-9a-fA-F]+),([0-9a-fA-F]+)\]\s+(.+)$`)
+)
+
+// reassigned are the reassign statuses of LBAs that were moved to a spare
+// sector, as opposed to still waiting for it or having failed to.
+var reassigned = map[string]bool{
+	"Successfully reassigned":              true,
+	"Reassigned by app, has valid data":    true,
+	"Reassigned by app, has no valid data": true,
+}
+
+func parseBackgroundScan(out string) *backgroundScan {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	scan := &backgroundScan{Entries: []*backgroundScanEntry{}}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Status:") {
+			scan.Status = strings.TrimSpace(strings.TrimPrefix(line, "Status:"))
+		} else if m := backgroundScansRgx.FindStringSubmatch(line); m != nil {
+			scan.Scans, _ = strconv.Atoi(m[1])
+			if m[2] != "" {
+				scan.ProgressPct, _ = strconv.ParseFloat(m[2], 64)
+				scan.HasProgress = true
+			}
+		} else if m := backgroundMediumScansRgx.FindStringSubmatch(line); m != nil {
+			scan.MediumScans, _ = strconv.Atoi(m[1])
+		} else if m := backgroundScanEntryRgx.FindStringSubmatch(line); m != nil {
+			entry := &backgroundScanEntry{PowerOnTime: m[1], ReassignStatus: strings.TrimSpace(m[6])}
+			entry.LBA, _ = strconv.ParseUint(m[2], 16, 64)
+			senseKey, _ := strconv.ParseInt(m[3], 16, 32)
+			asc, _ := strconv.ParseInt(m[4], 16, 32)
+			ascq, _ := strconv.ParseInt(m[5], 16, 32)
+			entry.SenseKey, entry.ASC, entry.ASCQ = int(senseKey), int(asc), int(ascq)
+			scan.Entries = append(scan.Entries, entry)
+		} else if !smartctlBoilerplate(line) && !backgroundScanHeaderRgx.MatchString(line) {
+			scan.Unrecognised = append(scan.Unrecognised, line)
+		}
+	}
+	return scan
+}
+
+// unrecovered tells whether the drive could not recover the data at the LBA,
+// i.e. it reported a medium or hardware error.
+func (entry *backgroundScanEntry) unrecovered() bool {
+	return entry.SenseKey == 3 || entry.SenseKey == 4
+}
+
+func (scan *backgroundScan) fields() []string {
+	unrecovered, reassignedCount := 0, 0
+	for _, entry := range scan.Entries {
+		if entry.unrecovered() {
+			unrecovered++
+		}
+		if reassigned[entry.ReassignStatus] {
+			reassignedCount++
+		}
+	}
+	fields := []string{
+		field("bms_status", scan.Status),
+		field("bms_scans", scan.Scans),
+		field("bms_medium_scans", scan.MediumScans),
+	}
+	if scan.HasProgress {
+		fields = append(fields, field("bms_progress_pct", scan.ProgressPct))
+	}
+	return append(fields,
+		field("bms_entries", len(scan.Entries)),
+		field("bms_unrecovered", unrecovered),
+		field("bms_reassigned", reassignedCount))
+}
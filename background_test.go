@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var backgroundOutput = `
+smartctl 6.6 2016-05-31 r4324 [x86_64-linux-4.9.0-3-amd64] (local build)
+Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
+
+=== START OF READ SMART DATA SECTION ===
+Background scan results log
+  Status: waiting until BMS interval timer expires
+    Accumulated power on time, hours:minutes 41619:47 [2497187 minutes]
+    Number of background scans performed: 388,  scan progress: 12.50%
+    Number of background medium scans performed: 388
+
+   #  when        lba(hex)    [sk,asc,ascq]    reassign_status
+   1 25535:41  0000000007f5b8d5  [1,17,1]   Recovered via rewrite in-place
+   2 38371:18  00000000230e4b2a  [3,11,0]   Require Write or Reassign Blocks command
+   3 41002:51  000000002a8c0e10  [3,11,0]   Reassigned by app, has valid data
+   4 41588:02  000000002b0e0a00  [b,47,3]   Reassignment by disk failed
+`
+
+func TestParseBackgroundScan(t *testing.T) {
+	scan := parseBackgroundScan(backgroundOutput)
+	assert.Equal(t, "waiting until BMS interval timer expires", scan.Status)
+	assert.Equal(t, 388, scan.Scans)
+	assert.Equal(t, 388, scan.MediumScans)
+	assert.Equal(t, 12.5, scan.ProgressPct)
+	assert.Equal(t, true, scan.HasProgress)
+	assert.Len(t, scan.Entries, 4)
+	assert.Equal(t, "25535:41", scan.Entries[0].PowerOnTime)
+	assert.Equal(t, uint64(0x7f5b8d5), scan.Entries[0].LBA)
+	assert.Equal(t, 1, scan.Entries[0].SenseKey)
+	assert.Equal(t, 0x17, scan.Entries[0].ASC)
+	assert.Equal(t, 1, scan.Entries[0].ASCQ)
+	assert.Equal(t, false, scan.Entries[0].unrecovered())
+	assert.Equal(t, true, scan.Entries[1].unrecovered())
+	assert.Equal(t, "Require Write or Reassign Blocks command", scan.Entries[1].ReassignStatus)
+	assert.Equal(t, 0xb, scan.Entries[3].SenseKey)
+	assert.Equal(t, 0x47, scan.Entries[3].ASC)
+	assert.Equal(t, false, scan.Entries[3].unrecovered())
+	assert.Empty(t, scan.Unrecognised)
+
+	assert.Equal(t, []string{
+		`bms_status="waiting until BMS interval timer expires"`,
+		"bms_scans=388",
+		"bms_medium_scans=388",
+		"bms_progress_pct=12.5",
+		"bms_entries=4",
+		"bms_unrecovered=2",
+		"bms_reassigned=1",
+	}, scan.fields())
+}
+
+var emptyBackgroundOutput = `
+Background scan results log
+  Status: scan is active
+    Accumulated power on time, hours:minutes 1102:05 [66125 minutes]
+    Number of background scans performed: 0,  scan progress: 0.00%
+    Number of background medium scans performed: 0
+`
+
+func TestParseEmptyBackgroundScan(t *testing.T) {
+	scan := parseBackgroundScan(emptyBackgroundOutput)
+	assert.Equal(t, "scan is active", scan.Status)
+	assert.Equal(t, 0, scan.Scans)
+	assert.Len(t, scan.Entries, 0)
+	assert.Contains(t, scan.fields(), "bms_unrecovered=0")
+}
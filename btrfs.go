@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type btrfsDeviceStats struct {
+	Device         string
+	WriteIOErrs    int64
+	ReadIOErrs     int64
+	FlushIOErrs    int64
+	CorruptionErrs int64
+	GenerationErrs int64
+}
+
+var btrfsDeviceStatRgx = regexp.MustCompile(`^\[(.+)\]\.(\w+)\s+(\d+)$`)
+
+func parseBtrfsDeviceStats(out string) []*btrfsDeviceStats {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	stats := []*btrfsDeviceStats{}
+	var current *btrfsDeviceStats
+	for _, line := range lines {
+		m := btrfsDeviceStatRgx.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		if current == nil || current.Device != m[1] {
+			current = &btrfsDeviceStats{Device: m[1]}
+			stats = append(stats, current)
+		}
+		value, _ := strconv.ParseInt(m[3], 10, 64)
+		switch m[2] {
+		case "write_io_errs":
+			current.WriteIOErrs = value
+		case "read_io_errs":
+			current.ReadIOErrs = value
+		case "flush_io_errs":
+			current.FlushIOErrs = value
+		case "corruption_errs":
+			current.CorruptionErrs = value
+		case "generation_errs":
+			current.GenerationErrs = value
+		}
+	}
+	return stats
+}
+
+// parseBtrfsMounts returns one mount point per btrfs filesystem listed in the
+// given /proc/mounts content, skipping further mounts of the same filesystem
+// such as subvolumes.
+func parseBtrfsMounts(out string) []string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	seen := map[string]bool{}
+	mounts := []string{}
+	for _, line := range lines {
+		columns := strings.Fields(line)
+		// mount points are passed to btrfs, so they must not look like options
+		if len(columns) < 3 || columns[2] != "btrfs" || seen[columns[0]] || !strings.HasPrefix(columns[1], "/") {
+			continue
+		}
+		seen[columns[0]] = true
+		mounts = append(mounts, unescapeMountPoint(columns[1]))
+	}
+	return mounts
+}
+
+var mountEscapeRgx = regexp.MustCompile(`\\[0-7]{3}`)
+
+// unescapeMountPoint decodes the octal escapes, e.g. \040 for a space, the
+// kernel writes in /proc/mounts for characters that would break its columns.
+func unescapeMountPoint(path string) string {
+	return mountEscapeRgx.ReplaceAllStringFunc(path, func(escape string) string {
+		code, _ := strconv.ParseUint(escape[1:], 8, 8)
+		return string([]byte{byte(code)})
+	})
+}
+
+func btrfsPoints(mount string, stats []*btrfsDeviceStats, devices []deviceInfo, hostname string) []point {
+	points := []point{}
+	for _, stat := range stats {
+		tags := []string{tag("host", hostname), tag("mount", mount), tag("device", stat.Device)}
+		if disk := findDisk(devices, stat.Device); disk != nil {
+			tags = append(tags, tag("disk", disk.Path))
+		}
+		points = append(points, point{
+			Measurement: *checkName + "_btrfs",
+			Tags:        tags,
+			Fields: []string{
+				field("write_io_errs", stat.WriteIOErrs),
+				field("read_io_errs", stat.ReadIOErrs),
+				field("flush_io_errs", stat.FlushIOErrs),
+				field("corruption_errs", stat.CorruptionErrs),
+				field("generation_errs", stat.GenerationErrs),
+			},
+		})
+	}
+	return points
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBtrfsDeviceStats(t *testing.T) {
+	var deviceStatsOutput = `
+[/dev/sda2].write_io_errs    0
+[/dev/sda2].read_io_errs     0
+[/dev/sda2].flush_io_errs    0
+[/dev/sda2].corruption_errs  0
+[/dev/sda2].generation_errs  0
+`
+	stats := parseBtrfsDeviceStats(deviceStatsOutput)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "/dev/sda2", stats[0].Device)
+	assert.Equal(t, int64(0), stats[0].WriteIOErrs)
+	assert.Equal(t, int64(0), stats[0].ReadIOErrs)
+	assert.Equal(t, int64(0), stats[0].FlushIOErrs)
+	assert.Equal(t, int64(0), stats[0].CorruptionErrs)
+	assert.Equal(t, int64(0), stats[0].GenerationErrs)
+}
+
+func TestParseMultiDeviceBtrfsDeviceStats(t *testing.T) {
+	var multiDeviceStatsOutput = `
+[/dev/sdb].write_io_errs    0
+[/dev/sdb].read_io_errs     0
+[/dev/sdb].flush_io_errs    0
+[/dev/sdb].corruption_errs  0
+[/dev/sdb].generation_errs  0
+[/dev/sdc].write_io_errs    1043
+[/dev/sdc].read_io_errs     217
+[/dev/sdc].flush_io_errs    12
+[/dev/sdc].corruption_errs  4
+[/dev/sdc].generation_errs  1
+[/dev/nvme0n1p3].write_io_errs    0
+[/dev/nvme0n1p3].read_io_errs     0
+[/dev/nvme0n1p3].flush_io_errs    0
+[/dev/nvme0n1p3].corruption_errs  9
+[/dev/nvme0n1p3].generation_errs  0
+`
+	stats := parseBtrfsDeviceStats(multiDeviceStatsOutput)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, "/dev/sdb", stats[0].Device)
+	assert.Equal(t, "/dev/sdc", stats[1].Device)
+	assert.Equal(t, int64(1043), stats[1].WriteIOErrs)
+	assert.Equal(t, int64(217), stats[1].ReadIOErrs)
+	assert.Equal(t, int64(12), stats[1].FlushIOErrs)
+	assert.Equal(t, int64(4), stats[1].CorruptionErrs)
+	assert.Equal(t, int64(1), stats[1].GenerationErrs)
+	assert.Equal(t, "/dev/nvme0n1p3", stats[2].Device)
+	assert.Equal(t, int64(9), stats[2].CorruptionErrs)
+}
+
+func TestParseBtrfsMounts(t *testing.T) {
+	var procMounts = `
+sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda2 / btrfs rw,relatime,ssd,space_cache,subvolid=257,subvol=/@ 0 0
+/dev/sda2 /home btrfs rw,relatime,ssd,space_cache,subvolid=258,subvol=/@home 0 0
+/dev/sda1 /boot ext4 rw,relatime 0 0
+/dev/sdb /srv/builds btrfs rw,relatime,space_cache,subvolid=5,subvol=/ 0 0
+/dev/sdc /srv/build\040cache\134old btrfs rw,relatime,space_cache,subvolid=5,subvol=/ 0 0
+`
+	assert.Equal(t, []string{"/", "/srv/builds", `/srv/build cache\old`}, parseBtrfsMounts(procMounts))
+}
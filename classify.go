@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Device classes, each of which can be reported, skipped or resolved to its
+// members through --class-action.
+const (
+	classPhysical   = "physical"
+	classRAIDVolume = "raid_volume"
+	classVirtual    = "virtual"
+	classRemovable  = "removable"
+)
+
+var defaultClassActions = map[string]string{
+	classPhysical:   "report",
+	classRAIDVolume: "resolve",
+	classVirtual:    "skip",
+	classRemovable:  "report",
+}
+
+var (
+	raidVolumeRgx  = regexp.MustCompile(`(?i)^((LSI|AVAGO|BROADCOM|DELL|IBM|Lenovo|FTS|INTEL) +(Logical Volume|MR\d|MegaRAID|PERC|ServeRAID|RAID)|HPE? +LOGICAL VOLUME|Adaptec )`)
+	virtualDiskRgx = regexp.MustCompile(`(?i)^(QEMU|VMware|VBOX|Msft +Virtual Disk|Virtual Disk|Xen|Red Hat +VirtIO|Google +PersistentDisk|Amazon Elastic Block Store)`)
+	virtualNameRgx = regexp.MustCompile(`^x?vd[a-z]+$`)
+)
+
+// classifyDevice tells what kind of device smartctl is looking at, from what
+// it reported and whether the kernel considers the device removable.
+func classifyDevice(device deviceInfo, info *smartCtlInfo, removable bool) string {
+	scsiName := strings.TrimSpace(info.Vendor + " " + info.Product)
+	switch {
+	case virtualNameRgx.MatchString(filepath.Base(device.Path)),
+		virtualDiskRgx.MatchString(scsiName), virtualDiskRgx.MatchString(info.DeviceModel):
+		return classVirtual
+	case raidVolumeRgx.MatchString(scsiName):
+		return classRAIDVolume
+	case removable:
+		return classRemovable
+	}
+	return classPhysical
+}
+
+// isRemovable tells whether the kernel flags the disk with the given kernel
+// name as removable media.
+func isRemovable(name string) bool {
+	if name == "" {
+		return false
+	}
+	data, err := ioutil.ReadFile(filepath.Join("/sys/block", name, "removable"))
+	return err == nil && strings.TrimSpace(string(data)) == "1"
+}
+
+// raidVolumeMemberType returns the smartctl device type prefix through which
+// the physical members of a RAID volume can be reached on the volume's own
+// device path, or an empty string if they cannot.
+func raidVolumeMemberType(info *smartCtlInfo) string {
+	switch strings.ToUpper(info.Vendor) {
+	case "HP", "HPE":
+		return "cciss"
+	case "LSI", "AVAGO", "BROADCOM", "DELL", "IBM", "LENOVO", "FTS", "INTEL":
+		return "megaraid"
+	}
+	return ""
+}
+
+var sysBlockHostRgx = regexp.MustCompile(`/host(\d+)/`)
+
+// scsiBusPath returns the /dev/bus/N path smartctl scans the controller of the
+// disk with the given kernel name as, from its SCSI host found under root,
+// usually /sys/block.
+func scsiBusPath(root, name string) string {
+	if name == "" {
+		return ""
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, name, "device"))
+	if err != nil {
+		return ""
+	}
+	if m := sysBlockHostRgx.FindStringSubmatch(path); m != nil {
+		return "/dev/bus/" + m[1]
+	}
+	return ""
+}
+
+// resolveRAIDVolume returns the physical members of a RAID volume, probed on
+// its device path. Members are not probed again if the devices already
+// include disks of the same controller type on the volume's path or on its
+// controller's /dev/bus/N path, if known.
+func resolveRAIDVolume(run runner, smartctlPath string, device deviceInfo, info *smartCtlInfo, devices []deviceInfo, controller string, max int) []deviceInfo {
+	memberType := raidVolumeMemberType(info)
+	if memberType == "" {
+		return nil
+	}
+	for _, scanned := range devices {
+		if scanned.Path != device.Path && (controller == "" || scanned.Path != controller) {
+			continue
+		}
+		if strings.HasPrefix(scanned.Type, memberType+",") || strings.Contains(scanned.Type, "+"+memberType+",") {
+			return nil
+		}
+	}
+	return probeControllerDisks(run, smartctlPath, device.Path, memberType, max)
+}
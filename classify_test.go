@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyDevice(t *testing.T) {
+	sda := deviceInfo{Path: "/dev/sda", Type: "auto"}
+	assert.Equal(t, classPhysical, classifyDevice(sda, &smartCtlInfo{DeviceModel: "HGST HDN724040ALE640"}, false))
+	assert.Equal(t, classPhysical, classifyDevice(deviceInfo{Path: "/dev/bus/0", Type: "megaraid,0"}, &smartCtlInfo{Vendor: "SEAGATE", Product: "ST4000NM0023"}, false))
+	assert.Equal(t, classRAIDVolume, classifyDevice(sda, &smartCtlInfo{Vendor: "LSI", Product: "Logical Volume"}, false))
+	assert.Equal(t, classRAIDVolume, classifyDevice(sda, &smartCtlInfo{Vendor: "DELL", Product: "PERC H730P Mini"}, false))
+	assert.Equal(t, classRAIDVolume, classifyDevice(sda, &smartCtlInfo{Vendor: "HP", Product: "LOGICAL VOLUME"}, false))
+	assert.Equal(t, classVirtual, classifyDevice(sda, &smartCtlInfo{Vendor: "QEMU", Product: "QEMU HARDDISK"}, false))
+	assert.Equal(t, classVirtual, classifyDevice(sda, &smartCtlInfo{Vendor: "VMware", Product: "Virtual disk"}, false))
+	assert.Equal(t, classVirtual, classifyDevice(sda, &smartCtlInfo{DeviceModel: "VBOX HARDDISK"}, false))
+	assert.Equal(t, classVirtual, classifyDevice(deviceInfo{Path: "/dev/vda", Type: "auto"}, &smartCtlInfo{}, false))
+	assert.Equal(t, classRemovable, classifyDevice(sda, &smartCtlInfo{Vendor: "Generic", Product: "STORAGE DEVICE"}, true))
+}
+
+func TestResolveRAIDVolume(t *testing.T) {
+	run := func(name string, args ...string) (string, string, error) {
+		if strings.Join(args, " ") == "-i /dev/sda -d megaraid,8" {
+			return `
+Vendor:               SEAGATE
+Product:              ST4000NM0023
+Device type:          disk
+`, "", nil
+		}
+		return "", "", nil
+	}
+	volume := deviceInfo{Path: "/dev/sda", Type: "auto"}
+	info := &smartCtlInfo{Vendor: "LSI", Product: "Logical Volume"}
+
+	members := resolveRAIDVolume(run, "/usr/sbin/smartctl", volume, info, []deviceInfo{volume}, "", 16)
+	assert.Equal(t, []deviceInfo{{Raw: "/dev/sda -d megaraid,8", Path: "/dev/sda", Type: "megaraid,8"}}, members)
+
+	// the scan already found the controller's members
+	scanned := []deviceInfo{volume, {Path: "/dev/bus/0", Type: "megaraid,8"}}
+	assert.Len(t, resolveRAIDVolume(run, "/usr/sbin/smartctl", volume, info, scanned, "/dev/bus/0", 16), 0)
+
+	// or they were resolved from another volume of the same controller
+	assert.Len(t, resolveRAIDVolume(run, "/usr/sbin/smartctl", volume, info, append([]deviceInfo{volume}, members...), "", 16), 0)
+
+	// members of another controller do not count
+	assert.Equal(t, members, resolveRAIDVolume(run, "/usr/sbin/smartctl", volume, info, scanned, "/dev/bus/1", 16))
+	assert.Equal(t, members, resolveRAIDVolume(run, "/usr/sbin/smartctl", volume, info, []deviceInfo{volume, {Path: "/dev/sdb", Type: "megaraid,0"}}, "", 16))
+
+	assert.Len(t, resolveRAIDVolume(run, "/usr/sbin/smartctl", volume, &smartCtlInfo{Vendor: "Adaptec", Product: "RAID5"}, []deviceInfo{volume}, "", 16), 0)
+}
+
+func TestSCSIBusPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk-health-checker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	device := filepath.Join(dir, "devices/pci0000:00/0000:00:03.0/0000:02:00.0/host2/target2:2:0/2:2:0:0")
+	assert.NoError(t, os.MkdirAll(device, 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "block", "sdb"), 0755))
+	assert.NoError(t, os.Symlink(device, filepath.Join(dir, "block", "sdb", "device")))
+
+	assert.Equal(t, "/dev/bus/2", scsiBusPath(filepath.Join(dir, "block"), "sdb"))
+	assert.Equal(t, "", scsiBusPath(filepath.Join(dir, "block"), "sdc"))
+	assert.Equal(t, "", scsiBusPath(filepath.Join(dir, "block"), ""))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// parserCoverage holds the lines of a device's smartctl output that were not
+// recognised, by parser. Lines outside of any known section are kept under
+// "output".
+type parserCoverage map[string][]string
+
+// sectionCoverage runs the parser of every section of a device's output and
+// collects the lines each of them did not recognise.
+func sectionCoverage(sections map[string]string) parserCoverage {
+	coverage := parserCoverage{}
+	add := func(parser string, lines []string) {
+		if len(lines) > 0 {
+			coverage[parser] = lines
+		}
+	}
+	add("info", parseSMARTCtlInfo(sections["info"]+sections["health"]).Unrecognised)
+	if out, ok := sections["attributes"]; ok {
+		_, lines := parseAttributeList(out)
+		add("attributes", lines)
+	}
+	if out, ok := sections["devstat"]; ok {
+		_, lines := parseDeviceStatistics(out)
+		add("devstat", lines)
+	}
+	if out, ok := sections["scttemp"]; ok {
+		add("scttemp", parseSCTTemperature(out).Unrecognised)
+	}
+	if out, ok := sections["sataphy"]; ok {
+		_, lines := parseSATAPhyEventCounters(out)
+		add("sataphy", lines)
+	}
+	if out, ok := sections["background"]; ok {
+		add("background", parseBackgroundScan(out).Unrecognised)
+	}
+	lines := []string{}
+	for _, line := range strings.Split(sections[""], "\n") {
+		if !smartctlBoilerplate(line) {
+			lines = append(lines, line)
+		}
+	}
+	add("output", lines)
+	return coverage
+}
+
+// count returns the number of unrecognised lines.
+func (coverage parserCoverage) count() int {
+	count := 0
+	for _, lines := range coverage {
+		count += len(lines)
+	}
+	return count
+}
+
+// parsers returns the names of the parsers with unrecognised lines, sorted.
+func (coverage parserCoverage) parsers() []string {
+	parsers := make([]string, 0, len(coverage))
+	for parser := range coverage {
+		parsers = append(parsers, parser)
+	}
+	sort.Strings(parsers)
+	return parsers
+}
+
+// coveragePoints reports, for each parser, how many lines it did not
+// recognise during this run and in the output of how many disks.
+func coveragePoints(coverages []parserCoverage, hostname string) []point {
+	lines, disks := map[string]int{}, map[string]int{}
+	for _, coverage := range coverages {
+		for parser, unrecognised := range coverage {
+			lines[parser] += len(unrecognised)
+			disks[parser]++
+		}
+	}
+	parsers := make([]string, 0, len(lines))
+	for parser := range lines {
+		parsers = append(parsers, parser)
+	}
+	sort.Strings(parsers)
+	points := []point{}
+	for _, parser := range parsers {
+		points = append(points, point{
+			Measurement: *checkName + "_parser",
+			Tags:        []string{tag("host", hostname), tag("parser", parser)},
+			Fields:      []string{field("unrecognised_lines", lines[parser]), field("disks", disks[parser])},
+		})
+	}
+	return points
+}
+
+// writeDoctorReport prints the lines of a device's output its parsers did
+// not recognise, for a parsing gap to be filed with the lines at hand.
+func writeDoctorReport(w io.Writer, device deviceInfo, info *smartCtlInfo, coverage parserCoverage) error {
+	model, firmware := info.modelAndFirmware()
+	if _, err := fmt.Fprintf(w, "%s -d %s: %s %s\n", device.Path, device.Type, model, firmware); err != nil {
+		return err
+	}
+	if len(coverage) == 0 {
+		_, err := fmt.Fprintln(w, "  all lines recognised")
+		return err
+	}
+	for _, parser := range coverage.parsers() {
+		if _, err := fmt.Fprintf(w, "  %s: %d unrecognised lines\n", parser, len(coverage[parser])); err != nil {
+			return err
+		}
+		for _, line := range coverage[parser] {
+			if _, err := fmt.Fprintf(w, "    %s\n", line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSectionCoverage(t *testing.T) {
+	for i, out := range []string{
+		diskInfoOutput + diskAttributesOutput,
+		ssdDiskInfoOutput + ssdDiskAttributesOutput,
+		raidVolumeInfoOutput,
+		devStatOutput,
+		sctTempOutput,
+		sataPhyOutput,
+		backgroundOutput,
+	} {
+		assert.Empty(t, sectionCoverage(splitSmartctlSections(out)), "fixture %d", i)
+	}
+
+	coverage := sectionCoverage(splitSmartctlSections(diskInfoOutput + diskAttributesOutput + `
+Write SCT (Get) Feature Control Command failed: scsi error aborted command
+`))
+	assert.Equal(t, parserCoverage{
+		"attributes": {"Write SCT (Get) Feature Control Command failed: scsi error aborted command"},
+	}, coverage)
+
+	attrs, unrecognised := parseAttributeList(`=== START OF READ SMART DATA SECTION ===
+SMART Attributes Data Structure revision number: 16
+Vendor Specific SMART Attributes with Thresholds:
+ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+  1 Raw_Read_Error_Rate     0x000b   100   100   016    Pre-fail  Always       -       0
+  9 Power_On_Hours
+`)
+	assert.Len(t, attrs, 1)
+	assert.Equal(t, []string{"  9 Power_On_Hours"}, unrecognised)
+}
+
+func TestCoveragePoints(t *testing.T) {
+	points := coveragePoints([]parserCoverage{
+		{"info": {"Gerätemodell:     HGST HDN724040ALE640", "Seriennummer:     PK2338P4H4XPXC"}},
+		{},
+		{"info": {"Gerätemodell:     HGST HDN724040ALE640"}, "attributes": {"  9 Power_On_Hours"}},
+	}, "db01")
+	assert.Len(t, points, 2)
+	assert.Equal(t, []string{"host=db01", "parser=attributes"}, points[0].Tags)
+	assert.Equal(t, []string{"unrecognised_lines=1", "disks=1"}, points[0].Fields)
+	assert.Equal(t, []string{"host=db01", "parser=info"}, points[1].Tags)
+	assert.Equal(t, []string{"unrecognised_lines=3", "disks=2"}, points[1].Fields)
+
+	assert.Empty(t, coveragePoints([]parserCoverage{{}, {}}, "db01"))
+}
+
+func TestWriteDoctorReport(t *testing.T) {
+	device := deviceInfo{Path: "/dev/sda", Type: "sat"}
+	info := parseSMARTCtlInfo(diskInfoOutput)
+
+	var out bytes.Buffer
+	assert.NoError(t, writeDoctorReport(&out, device, info, parserCoverage{}))
+	assert.Equal(t, `/dev/sda -d sat: HGST HDN724040ALE640 MJAOA5E0
+  all lines recognised
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, writeDoctorReport(&out, device, info, parserCoverage{
+		"output":     {"Write SCT (Get) Feature Control Command failed: scsi error aborted command"},
+		"attributes": {"  9 Power_On_Hours", "190 Airflow_Temperature_Cel"},
+	}))
+	assert.Equal(t, `/dev/sda -d sat: HGST HDN724040ALE640 MJAOA5E0
+  attributes: 2 unrecognised lines
+      9 Power_On_Hours
+    190 Airflow_Temperature_Cel
+  output: 1 unrecognised lines
+    Write SCT (Get) Feature Control Command failed: scsi error aborted command
+`, out.String())
+}
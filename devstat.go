@@ -0,0 +1,77 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// deviceStatistic is a single entry of the ATA Device Statistics log.
+type deviceStatistic struct {
+	Page        int
+	Offset      int
+	Size        int
+	Value       int64
+	Valid       bool
+	Flags       string
+	Description string
+}
+
+var (
+	deviceStatisticRgx     = regexp.MustCompile(`^0x([0-9a-fA-F]{2})\s+0x([0-9a-fA-F]{3})\s+(\d+)\s+(-?\d+|-)\s+([-NDC]{3})\s+(.+)$`)
+	deviceStatisticNameRgx = regexp.MustCompile(`[^a-z0-9]+`)
+	// deviceStatisticsHeaderRgx matches the title, column headers, page
+	// headers and flags legend of the log
+	deviceStatisticsHeaderRgx = regexp.MustCompile(`^(Device Statistics \(|Page\s+Offset\s+Size|0x[0-9a-fA-F]{2}\s+=====|\|+_)`)
+)
+
+// parseDeviceStatistics also returns the lines that are neither statistics
+// nor headers.
+func parseDeviceStatistics(out string) ([]*deviceStatistic, []string) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	stats := []*deviceStatistic{}
+	unrecognised := []string{}
+	for _, line := range lines {
+		m := deviceStatisticRgx.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			if !smartctlBoilerplate(line) && !deviceStatisticsHeaderRgx.MatchString(strings.TrimSpace(line)) {
+				unrecognised = append(unrecognised, line)
+			}
+			continue
+		}
+		page, _ := strconv.ParseInt(m[1], 16, 32)
+		offset, _ := strconv.ParseInt(m[2], 16, 32)
+		stat := &deviceStatistic{Page: int(page), Offset: int(offset), Flags: m[5], Description: strings.TrimSpace(m[6])}
+		stat.Size, _ = strconv.Atoi(m[3])
+		if value, err := strconv.ParseInt(m[4], 10, 64); err == nil {
+			stat.Value, stat.Valid = value, true
+		}
+		stats = append(stats, stat)
+	}
+	return stats, unrecognised
+}
+
+// name returns the description in snake case, e.g. logical_sectors_written.
+func (stat *deviceStatistic) name() string {
+	return strings.Trim(deviceStatisticNameRgx.ReplaceAllString(strings.ToLower(stat.Description), "_"), "_")
+}
+
+func deviceStatisticsFields(stats []*deviceStatistic) []string {
+	fields := []string{}
+	for _, stat := range stats {
+		if stat.Valid {
+			fields = append(fields, field("devstat_"+stat.name(), stat.Value))
+		}
+	}
+	return fields
+}
+
+// lookupDeviceStatistic returns the valid statistic with the given name.
+func lookupDeviceStatistic(stats []*deviceStatistic, name string) (int64, bool) {
+	for _, stat := range stats {
+		if stat.Valid && stat.name() == name {
+			return stat.Value, true
+		}
+	}
+	return 0, false
+}
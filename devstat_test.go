@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var devStatOutput = `
+smartctl 6.6 2016-05-31 r4324 [x86_64-linux-4.4.35-2-pve] (local build)
+Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
+
+Device Statistics (GP Log 0x04)
+Page  Offset Size        Value Flags Description
+0x01  =====  =               =  ===  == General Statistics (rev 1) ==
+0x01  0x008  4             31  ---  Lifetime Power-On Resets
+0x01  0x010  4          33211  ---  Power-on Hours
+0x01  0x018  6    20081879990  ---  Logical Sectors Written
+0x01  0x020  6      161928355  ---  Number of Write Commands
+0x01  0x028  6    54631063470  ---  Logical Sectors Read
+0x01  0x030  6      285019412  ---  Number of Read Commands
+0x01  0x038  6              -  ---  Date and Time TimeStamp
+0x03  =====  =               =  ===  == Rotating Media Statistics (rev 1) ==
+0x03  0x008  4          33148  ---  Spindle Motor Power-on Hours
+0x03  0x010  4          33148  ---  Head Flying Hours
+0x03  0x018  4         205572  ---  Head Load Events
+0x03  0x020  4              0  ---  Number of Reallocated Logical Sectors
+0x03  0x028  4              0  ---  Read Recovery Attempts
+0x03  0x030  4              0  ---  Number of Mechanical Start Failures
+0x04  =====  =               =  ===  == General Errors Statistics (rev 1) ==
+0x04  0x008  4              0  ---  Number of Reported Uncorrectable Errors
+0x04  0x010  4              0  ---  Resets Between Cmd Acceptance and Completion
+0x05  =====  =               =  ===  == Temperature Statistics (rev 1) ==
+0x05  0x008  1             26  ---  Current Temperature
+0x05  0x020  1             41  ---  Highest Temperature
+0x05  0x028  1             18  ---  Lowest Temperature
+0x05  0x058  1             60  ---  Specified Maximum Operating Temperature
+0x07  =====  =               =  ===  == Solid State Device Statistics (rev 1) ==
+0x07  0x008  1              3  N--  Percentage Used Endurance Indicator
+                                |||_ C monitored condition met
+                                ||__ D supports DSN
+                                |___ N normalized value
+
+`
+
+func TestParseDeviceStatistics(t *testing.T) {
+	stats, _ := parseDeviceStatistics(devStatOutput)
+	assert.Len(t, stats, 20)
+	assert.Equal(t, 1, stats[2].Page)
+	assert.Equal(t, 0x018, stats[2].Offset)
+	assert.Equal(t, 6, stats[2].Size)
+	assert.Equal(t, int64(20081879990), stats[2].Value)
+	assert.Equal(t, "logical_sectors_written", stats[2].name())
+	assert.Equal(t, false, stats[6].Valid)
+	assert.Equal(t, "N--", stats[19].Flags)
+
+	fields := deviceStatisticsFields(stats)
+	assert.Len(t, fields, 19)
+	assert.Contains(t, fields, "devstat_number_of_mechanical_start_failures=0")
+	assert.Contains(t, fields, "devstat_number_of_reported_uncorrectable_errors=0")
+	assert.Contains(t, fields, "devstat_highest_temperature=41")
+	assert.Contains(t, fields, "devstat_percentage_used_endurance_indicator=3")
+
+	endurance := newSSDEndurance(&smartCtlInfo{DeviceModel: "SOME SSD", LogicalSectorSizes: 512}, nil, defaultDriveDB)
+	endurance.fillFromDeviceStatistics(&smartCtlInfo{LogicalSectorSizes: 512}, stats)
+	assert.Equal(t, float64(20081879990)*512, endurance.BytesWritten)
+	assert.Equal(t, 3, endurance.LifeUsedPct)
+}
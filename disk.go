@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var partitionRgx = regexp.MustCompile(`^(/dev/(?:nvme\d+n\d+|mmcblk\d+))p\d+$|^(/dev/(?:[shv]d|xvd)[a-z]+)\d+$`)
+
+// parentDisk strips the partition suffix from a device path, so that
+// /dev/sda1 becomes /dev/sda and /dev/nvme0n1p2 becomes /dev/nvme0n1.
+func parentDisk(path string) string {
+	if m := partitionRgx.FindStringSubmatch(path); m != nil {
+		if m[1] != "" {
+			return m[1]
+		}
+		return m[2]
+	}
+	return path
+}
+
+// resolveDevice returns the /dev path a device name refers to, following
+// udev symlinks such as the ones in /dev/disk/by-id.
+func resolveDevice(name string) string {
+	candidates := []string{name}
+	if !filepath.IsAbs(name) {
+		candidates = []string{
+			filepath.Join("/dev/disk/by-id", name),
+			filepath.Join("/dev/disk/by-vdev", name),
+			filepath.Join("/dev/disk/by-partuuid", name),
+			filepath.Join("/dev", name),
+		}
+	}
+	for _, candidate := range candidates {
+		if path, err := filepath.EvalSymlinks(candidate); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// findDisk returns the scanned device backing the given device or partition
+// name, or nil if it is not one of the scanned disks.
+func findDisk(devices []deviceInfo, name string) *deviceInfo {
+	path := resolveDevice(name)
+	if path == "" {
+		return nil
+	}
+	path = parentDisk(path)
+	for i := range devices {
+		if devices[i].Path == path || resolveDevice(devices[i].Path) == path {
+			return &devices[i]
+		}
+	}
+	return nil
+}
+
+// controllerMemberRgx matches the smartctl device types addressing a disk
+// behind a RAID controller, through the path of the controller or of one of
+// its volumes.
+var controllerMemberRgx = regexp.MustCompile(`(^|\+)(megaraid|cciss|aacraid),`)
+
+// deviceKernelName returns the kernel name of the block device smartctl reads,
+// or an empty string for a disk behind a RAID controller, whose path is not
+// its own.
+func deviceKernelName(device deviceInfo) string {
+	if controllerMemberRgx.MatchString(device.Type) {
+		return ""
+	}
+	return kernelName(device.Path)
+}
+
+// kernelName returns the kernel block device name of a device path, e.g. sdb
+// for /dev/sdb or /dev/disk/by-id/ata-HGST_HDN724040ALE640_PK2338P4H4XPXC.
+func kernelName(path string) string {
+	resolved := resolveDevice(path)
+	if resolved == "" || !strings.HasPrefix(resolved, "/dev/") || strings.Count(resolved, "/") != 2 {
+		return ""
+	}
+	return filepath.Base(resolved)
+}
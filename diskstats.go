@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// diskStats holds the I/O counters of a block device, as documented in the
+// kernel's Documentation/admin-guide/iostats.rst.
+type diskStats struct {
+	Name             string
+	ReadsCompleted   uint64
+	ReadsMerged      uint64
+	SectorsRead      uint64
+	ReadTimeMS       uint64
+	WritesCompleted  uint64
+	WritesMerged     uint64
+	SectorsWritten   uint64
+	WriteTimeMS      uint64
+	IOInProgress     uint64
+	IOTimeMS         uint64
+	WeightedIOTimeMS uint64
+}
+
+func parseDiskStats(out string) map[string]*diskStats {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	stats := make(map[string]*diskStats, len(lines))
+	for _, line := range lines {
+		columns := strings.Fields(line)
+		if len(columns) < 14 {
+			continue
+		}
+		values := make([]uint64, 11)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(columns[i+3], 10, 64)
+		}
+		stats[columns[2]] = &diskStats{
+			Name:             columns[2],
+			ReadsCompleted:   values[0],
+			ReadsMerged:      values[1],
+			SectorsRead:      values[2],
+			ReadTimeMS:       values[3],
+			WritesCompleted:  values[4],
+			WritesMerged:     values[5],
+			SectorsWritten:   values[6],
+			WriteTimeMS:      values[7],
+			IOInProgress:     values[8],
+			IOTimeMS:         values[9],
+			WeightedIOTimeMS: values[10],
+		}
+	}
+	return stats
+}
+
+// await returns the average time in milliseconds reads, writes and all I/Os
+// completed since prev took. ok is false when the counters went backwards,
+// which happens when the machine rebooted or the device was replaced.
+func (s *diskStats) await(prev *diskStats) (read, write, total float64, ok bool) {
+	if s.ReadsCompleted < prev.ReadsCompleted || s.WritesCompleted < prev.WritesCompleted ||
+		s.ReadTimeMS < prev.ReadTimeMS || s.WriteTimeMS < prev.WriteTimeMS {
+		return 0, 0, 0, false
+	}
+	reads := s.ReadsCompleted - prev.ReadsCompleted
+	writes := s.WritesCompleted - prev.WritesCompleted
+	readTime := s.ReadTimeMS - prev.ReadTimeMS
+	writeTime := s.WriteTimeMS - prev.WriteTimeMS
+	if reads > 0 {
+		read = float64(readTime) / float64(reads)
+	}
+	if writes > 0 {
+		write = float64(writeTime) / float64(writes)
+	}
+	if reads+writes > 0 {
+		total = float64(readTime+writeTime) / float64(reads+writes)
+	}
+	return read, write, total, true
+}
+
+func (s *diskStats) fields() []string {
+	return []string{
+		field("reads_completed", s.ReadsCompleted),
+		field("reads_merged", s.ReadsMerged),
+		field("sectors_read", s.SectorsRead),
+		field("read_time_ms", s.ReadTimeMS),
+		field("writes_completed", s.WritesCompleted),
+		field("writes_merged", s.WritesMerged),
+		field("sectors_written", s.SectorsWritten),
+		field("write_time_ms", s.WriteTimeMS),
+		field("io_in_progress", s.IOInProgress),
+		field("io_time_ms", s.IOTimeMS),
+		field("weighted_io_time_ms", s.WeightedIOTimeMS),
+	}
+}
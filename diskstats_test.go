@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiskStats(t *testing.T) {
+	var procDiskStats = `
+   8       0 sda 1833604 88323 96372730 1146928 13421707 6577041 428412096 22815372 0 6270152 23964684 0 0 0 0 131224 2384
+   8       1 sda1 1833411 88323 96365522 1146880 13421707 6577041 428412096 22815372 0 6270120 23962252 0 0 0 0 0 0
+   8      16 sdb 98123 1021 8823112 613201 312 98 9210 1203 2 712034 614404
+ 259       0 nvme0n1 7654321 12 612341234 1234567 8765432 23 812345678 2345678 0 3456789 3580245 0 0 0 0 0 0
+`
+	stats := parseDiskStats(procDiskStats)
+	assert.Len(t, stats, 4)
+	sda := stats["sda"]
+	assert.Equal(t, uint64(1833604), sda.ReadsCompleted)
+	assert.Equal(t, uint64(88323), sda.ReadsMerged)
+	assert.Equal(t, uint64(96372730), sda.SectorsRead)
+	assert.Equal(t, uint64(1146928), sda.ReadTimeMS)
+	assert.Equal(t, uint64(13421707), sda.WritesCompleted)
+	assert.Equal(t, uint64(6577041), sda.WritesMerged)
+	assert.Equal(t, uint64(428412096), sda.SectorsWritten)
+	assert.Equal(t, uint64(22815372), sda.WriteTimeMS)
+	assert.Equal(t, uint64(0), sda.IOInProgress)
+	assert.Equal(t, uint64(6270152), sda.IOTimeMS)
+	assert.Equal(t, uint64(23964684), sda.WeightedIOTimeMS)
+	assert.Equal(t, uint64(2), stats["sdb"].IOInProgress)
+	assert.Equal(t, uint64(7654321), stats["nvme0n1"].ReadsCompleted)
+}
+
+func TestDiskStatsAwait(t *testing.T) {
+	prev := &diskStats{ReadsCompleted: 1000, ReadTimeMS: 5000, WritesCompleted: 2000, WriteTimeMS: 8000}
+	cur := &diskStats{ReadsCompleted: 1100, ReadTimeMS: 5800, WritesCompleted: 2300, WriteTimeMS: 9200}
+	read, write, total, ok := cur.await(prev)
+	assert.True(t, ok)
+	assert.Equal(t, 8.0, read)
+	assert.Equal(t, 4.0, write)
+	assert.Equal(t, 5.0, total)
+
+	_, _, _, ok = prev.await(cur)
+	assert.False(t, ok)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// driveAttribute is the canonical meaning of a SMART attribute on a drive.
+type driveAttribute struct {
+	// Name is a semantic, vendor neutral name such as reallocated_sectors
+	Name string `json:"name"`
+	// Unit is what the raw value measures once multiplied by Scale
+	Unit string `json:"unit,omitempty"`
+	// Scale converts the raw value into Unit, 0 meaning 1
+	Scale float64 `json:"scale,omitempty"`
+	// Format is how the raw value is written: raw48 (the default) or duration
+	Format string `json:"format,omitempty"`
+	// Endurance marks the attribute counting an SSD's host writes ("written")
+	// or whose normalized value is its remaining life in percent ("life")
+	Endurance string `json:"endurance,omitempty"`
+}
+
+// driveEntry describes the attributes of the drives whose model and firmware
+// match the given regular expressions.
+type driveEntry struct {
+	Vendor     string                 `json:"vendor,omitempty"`
+	Model      string                 `json:"model"`
+	Firmware   string                 `json:"firmware,omitempty"`
+	Attributes map[int]driveAttribute `json:"attributes"`
+
+	modelRgx    *regexp.Regexp
+	firmwareRgx *regexp.Regexp
+}
+
+type driveDB []*driveEntry
+
+// defaultDriveDB starts with the attributes most vendors agree on, followed by
+// vendor specific entries overriding them.
+var defaultDriveDB = driveDB{
+	{
+		Model: `.`,
+		Attributes: map[int]driveAttribute{
+			1:   {Name: "read_error_rate"},
+			3:   {Name: "spin_up_time", Unit: "ms"},
+			4:   {Name: "start_stop_count"},
+			5:   {Name: "reallocated_sectors"},
+			7:   {Name: "seek_error_rate"},
+			9:   {Name: "power_on_hours", Unit: "hours"},
+			10:  {Name: "spin_retry_count"},
+			12:  {Name: "power_cycles"},
+			177: {Name: "wear_leveling_count", Endurance: "life"},
+			187: {Name: "reported_uncorrectable"},
+			188: {Name: "command_timeouts"},
+			190: {Name: "airflow_temperature", Unit: "celsius"},
+			192: {Name: "power_off_retracts"},
+			193: {Name: "load_cycles"},
+			194: {Name: "temperature", Unit: "celsius"},
+			196: {Name: "reallocation_events"},
+			197: {Name: "pending_sectors"},
+			198: {Name: "offline_uncorrectable"},
+			199: {Name: "udma_crc_errors"},
+			202: {Name: "percent_lifetime_used", Unit: "percent", Endurance: "life"},
+			231: {Name: "ssd_life_left", Unit: "percent", Endurance: "life"},
+			233: {Name: "media_wearout_indicator", Endurance: "life"},
+			240: {Name: "head_flying_hours", Unit: "hours", Format: "duration"},
+			241: {Name: "total_lbas_written", Unit: "sectors", Endurance: "written"},
+		},
+	},
+	{
+		Vendor: "Intel",
+		Model:  `^INTEL SSD`,
+		Attributes: map[int]driveAttribute{
+			170: {Name: "available_reserved_space", Unit: "percent"},
+			171: {Name: "program_fail_count"},
+			172: {Name: "erase_fail_count"},
+			174: {Name: "unexpected_power_loss_count"},
+			183: {Name: "sata_downshift_count"},
+			184: {Name: "end_to_end_errors"},
+			225: {Name: "legacy_host_writes_bytes", Unit: "bytes", Scale: 32 << 20, Endurance: "written"},
+			226: {Name: "timed_workload_media_wear"},
+			227: {Name: "timed_workload_host_reads", Unit: "percent"},
+			228: {Name: "timed_workload_timer", Unit: "minutes"},
+			232: {Name: "reserve_space_remaining", Unit: "percent"},
+			233: {Name: "media_wearout_indicator", Endurance: "life"},
+			241: {Name: "host_writes_bytes", Unit: "bytes", Scale: 32 << 20, Endurance: "written"},
+			242: {Name: "host_reads_bytes", Unit: "bytes", Scale: 32 << 20},
+			249: {Name: "nand_writes_bytes", Unit: "bytes", Scale: 1 << 30},
+		},
+	},
+	{
+		Vendor: "Samsung",
+		Model:  `^Samsung SSD`,
+		Attributes: map[int]driveAttribute{
+			177: {Name: "wear_leveling_count", Endurance: "life"},
+			179: {Name: "used_reserved_blocks"},
+			181: {Name: "program_fail_count"},
+			182: {Name: "erase_fail_count"},
+			183: {Name: "runtime_bad_blocks"},
+			235: {Name: "unexpected_power_loss_count"},
+			241: {Name: "host_writes_bytes", Unit: "bytes", Scale: 512, Endurance: "written"},
+		},
+	},
+	{
+		Vendor: "Micron",
+		Model:  `^Micron`,
+		Attributes: map[int]driveAttribute{
+			171: {Name: "program_fail_count"},
+			172: {Name: "erase_fail_count"},
+			173: {Name: "average_block_erase_count"},
+			174: {Name: "unexpected_power_loss_count"},
+			202: {Name: "percent_lifetime_used", Unit: "percent", Endurance: "life"},
+			246: {Name: "host_writes_bytes", Unit: "bytes", Scale: 512, Endurance: "written"},
+		},
+	},
+	{
+		Vendor: "Crucial",
+		Model:  `^(Crucial|CT\d)`,
+		Attributes: map[int]driveAttribute{
+			171: {Name: "program_fail_count"},
+			172: {Name: "erase_fail_count"},
+			173: {Name: "average_block_erase_count"},
+			174: {Name: "unexpected_power_loss_count"},
+			202: {Name: "percent_lifetime_used", Unit: "percent", Endurance: "life"},
+			246: {Name: "host_writes_bytes", Unit: "bytes", Scale: 512, Endurance: "written"},
+		},
+	},
+	{
+		Vendor: "Kingston",
+		Model:  `^KINGSTON`,
+		Attributes: map[int]driveAttribute{
+			231: {Name: "ssd_life_left", Unit: "percent", Endurance: "life"},
+			241: {Name: "host_writes_bytes", Unit: "bytes", Scale: 1 << 30, Endurance: "written"},
+			242: {Name: "host_reads_bytes", Unit: "bytes", Scale: 1 << 30},
+		},
+	},
+	{
+		Vendor: "SanDisk",
+		Model:  `^SanDisk`,
+		Attributes: map[int]driveAttribute{
+			230: {Name: "media_wearout_indicator", Endurance: "life"},
+			232: {Name: "available_reserved_space", Unit: "percent"},
+			233: {Name: "nand_writes_bytes", Unit: "bytes", Scale: 1 << 30},
+			241: {Name: "host_writes_bytes", Unit: "bytes", Scale: 1 << 30, Endurance: "written"},
+			242: {Name: "host_reads_bytes", Unit: "bytes", Scale: 1 << 30},
+		},
+	},
+	{
+		Model: `^(ST|Seagate)`,
+		Attributes: map[int]driveAttribute{
+			241: {Name: "host_writes_bytes", Unit: "bytes", Scale: 512},
+			242: {Name: "host_reads_bytes", Unit: "bytes", Scale: 512},
+		},
+	},
+}
+
+func init() {
+	if err := defaultDriveDB.compile(); err != nil {
+		panic(err)
+	}
+}
+
+func (db driveDB) compile() (err error) {
+	for _, entry := range db {
+		if entry.modelRgx, err = regexp.Compile(entry.Model); err != nil {
+			return err
+		}
+		if entry.firmwareRgx, err = regexp.Compile(entry.Firmware); err != nil {
+			return err
+		}
+		// a name given twice would be written twice in the same point
+		names := map[string]int{}
+		for id, attribute := range entry.Attributes {
+			if other, ok := names[attribute.Name]; ok {
+				return fmt.Errorf("drive %q: attributes %d and %d are both named %q", entry.Model, other, id, attribute.Name)
+			}
+			names[attribute.Name] = id
+		}
+	}
+	return nil
+}
+
+// loadDriveDB reads additional entries from a JSON file, in the same format as
+// defaultDriveDB, to be appended to it.
+func loadDriveDB(path string) (driveDB, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db := driveDB{}
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	return db, db.compile()
+}
+
+// lookup merges the attributes of every entry matching the given model and
+// firmware, later entries taking precedence. An attribute whose name a later
+// entry gives to another ID is dropped, so every name stays unique in the
+// merged map.
+func (db driveDB) lookup(model, firmware string) map[int]driveAttribute {
+	attributes := map[int]driveAttribute{}
+	for _, entry := range db {
+		if !entry.modelRgx.MatchString(model) || !entry.firmwareRgx.MatchString(firmware) {
+			continue
+		}
+		names := make(map[string]int, len(attributes))
+		for id, attribute := range attributes {
+			names[attribute.Name] = id
+		}
+		for id, attribute := range entry.Attributes {
+			if other, ok := names[attribute.Name]; ok && other != id {
+				if _, overridden := entry.Attributes[other]; !overridden {
+					delete(attributes, other)
+				}
+			}
+			attributes[id] = attribute
+		}
+	}
+	return attributes
+}
+
+// enduranceAttributes returns the vendor of the given drive and the IDs of
+// the attributes counting its host writes and its remaining life, those of
+// the most specific entries first.
+func (db driveDB) enduranceAttributes(model, firmware string) (vendor string, written, life []int) {
+	for i := len(db) - 1; i >= 0; i-- {
+		entry := db[i]
+		if !entry.modelRgx.MatchString(model) || !entry.firmwareRgx.MatchString(firmware) {
+			continue
+		}
+		if vendor == "" {
+			vendor = entry.Vendor
+		}
+		ids := make([]int, 0, len(entry.Attributes))
+		for id := range entry.Attributes {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			switch entry.Attributes[id].Endurance {
+			case "written":
+				written = append(written, id)
+			case "life":
+				life = append(life, id)
+			}
+		}
+	}
+	return vendor, written, life
+}
+
+var durationRawValueRgx = regexp.MustCompile(`^(\d+)h\+(\d+)m\+([\d.]+)s`)
+
+// value returns the raw value of attr converted to the attribute's unit.
+func (da driveAttribute) value(attr *smartAttribute) float64 {
+	raw := float64(attr.RawValue)
+	if da.Format == "duration" {
+		raw = 0
+		if m := durationRawValueRgx.FindStringSubmatch(attr.RawString); m != nil {
+			hours, _ := strconv.ParseFloat(m[1], 64)
+			minutes, _ := strconv.ParseFloat(m[2], 64)
+			seconds, _ := strconv.ParseFloat(m[3], 64)
+			raw = hours + minutes/60 + seconds/3600
+		}
+	}
+	if da.Scale != 0 {
+		raw *= da.Scale
+	}
+	return raw
+}
+
+// canonicalize replaces the placeholder names smartctl gives to attributes
+// missing from its own database by their canonical names.
+func canonicalize(attrs []*smartAttribute, known map[int]driveAttribute) {
+	for _, attr := range attrs {
+		if da, ok := known[attr.ID]; ok && strings.HasPrefix(attr.Name, "Unknown_") {
+			attr.Name = da.Name
+		}
+	}
+}
+
+// semanticString formats attr like smartAttribute.String, using the canonical
+// name instead of the attribute ID and the raw value in the canonical unit.
+func (da driveAttribute) semanticString(attr *smartAttribute) string {
+	return strings.Join([]string{
+		field(da.Name, da.value(attr)),
+		field(da.Name+"_value", attr.Value),
+		field(da.Name+"_worst", attr.Worst),
+		field(da.Name+"_thresh", attr.Thresh),
+	}, ",")
+}
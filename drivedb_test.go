@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriveDBLookup(t *testing.T) {
+	known := defaultDriveDB.lookup("INTEL SSDSC2BW480H6", "RG20")
+	assert.Equal(t, "reallocated_sectors", known[5].Name)
+	assert.Equal(t, "available_reserved_space", known[170].Name)
+	assert.Equal(t, "host_writes_bytes", known[241].Name)
+	assert.Equal(t, float64(32<<20), known[241].Scale)
+
+	known = defaultDriveDB.lookup("HGST HDN724040ALE640", "MJAOA5E0")
+	assert.Equal(t, "temperature", known[194].Name)
+	_, ok := known[170]
+	assert.False(t, ok)
+}
+
+func TestDriveDBUniqueNames(t *testing.T) {
+	models := []string{"HGST HDN724040ALE640", "INTEL SSDSC2BW480H6", "Samsung SSD 850 PRO 512GB", "Micron_5100_MTFDDAK960TCB",
+		"CT500MX500SSD1", "KINGSTON SA400S37240G", "SanDisk SDSSDH3500G", "ST3000DM001-1CH166"}
+	for _, model := range models {
+		names := map[string]int{}
+		for id, attribute := range defaultDriveDB.lookup(model, "") {
+			other, ok := names[attribute.Name]
+			assert.False(t, ok, "%s: attributes %d and %d are both named %s", model, other, id, attribute.Name)
+			names[attribute.Name] = id
+		}
+	}
+}
+
+func TestDriveDBMergedNames(t *testing.T) {
+	db := append(driveDB{}, defaultDriveDB...)
+	db = append(db, &driveEntry{
+		Model: `^HGST`,
+		Attributes: map[int]driveAttribute{
+			22: {Name: "temperature", Unit: "celsius"},
+			23: {Name: "helium_level"},
+		},
+	})
+	assert.NoError(t, db.compile())
+
+	known := db.lookup("HGST HUH721212ALE604", "LEGNW3D0")
+	assert.Equal(t, "temperature", known[22].Name)
+	_, ok := known[194]
+	assert.False(t, ok)
+	assert.Equal(t, "airflow_temperature", known[190].Name)
+
+	// the earlier attribute stays when the later entry also redefines its ID
+	db[len(db)-1].Attributes[194] = driveAttribute{Name: "internal_temperature", Unit: "celsius"}
+	known = db.lookup("HGST HUH721212ALE604", "LEGNW3D0")
+	assert.Equal(t, "temperature", known[22].Name)
+	assert.Equal(t, "internal_temperature", known[194].Name)
+}
+
+func TestCanonicalizeUnknownAttributes(t *testing.T) {
+	var unknownAttributesOutput = `
+ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+  5 Reallocated_Sector_Ct   0x0032   100   100   000    Old_age   Always       -       0
+170 Unknown_Attribute       0x0033   097   100   010    Pre-fail  Always       -       0
+225 Unknown_SSD_Attribute   0x0032   100   100   000    Old_age   Always       -       1726241
+241 Total_LBAs_Written      0x0032   100   100   000    Old_age   Always       -       1726241
+`
+	attrs, _ := parseAttributeList(unknownAttributesOutput)
+	known := defaultDriveDB.lookup("INTEL SSDSC2BW480H6", "RG20")
+	canonicalize(attrs, known)
+	assert.Equal(t, "Reallocated_Sector_Ct", attrs[0].Name)
+	assert.Equal(t, "available_reserved_space", attrs[1].Name)
+	assert.Equal(t, "legacy_host_writes_bytes", attrs[2].Name)
+	assert.Equal(t, "Total_LBAs_Written", attrs[3].Name)
+
+	assert.Equal(t, "host_writes_bytes=57923036250112,host_writes_bytes_value=100,host_writes_bytes_worst=100,host_writes_bytes_thresh=0",
+		known[241].semanticString(attrs[3]))
+}
+
+func TestDriveAttributeDuration(t *testing.T) {
+	attrs, _ := parseAttributeList(`
+ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+240 Head_Flying_Hours       0x0000   100   253   000    Old_age   Offline      -       31467h+00m+36.000s
+`)
+	known := defaultDriveDB.lookup("ST3000DM001-1CH166", "CC27")
+	assert.Equal(t, 31467.01, known[240].value(attrs[0]))
+}
+
+func TestLoadDriveDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk-health-checker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "drivedb.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`[
+		{"model": "^INTEL", "attributes": {
+			"170": {"name": "host_writes_bytes"},
+			"241": {"name": "host_writes_bytes"}
+		}}
+	]`), 0644))
+	_, err = loadDriveDB(path)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`[
+		{"model": "^INTEL SSDSC2BW", "firmware": "^RG2", "attributes": {
+			"170": {"name": "reserve_blocks_left", "unit": "percent"},
+			"241": {"name": "host_writes_bytes", "unit": "bytes", "scale": 1073741824, "endurance": "written"},
+			"249": {"name": "nand_writes_bytes", "unit": "bytes", "scale": 1073741824}
+		}}
+	]`), 0644))
+	extra, err := loadDriveDB(path)
+	assert.NoError(t, err)
+	db := append(append(driveDB{}, defaultDriveDB...), extra...)
+	known := db.lookup("INTEL SSDSC2BW480H6", "RG20")
+	assert.Equal(t, "reserve_blocks_left", known[170].Name)
+	assert.Equal(t, "host_writes_bytes", known[241].Name)
+	known = db.lookup("INTEL SSDSC2BW480H6", "RG10")
+	assert.Equal(t, "available_reserved_space", known[170].Name)
+
+	// endurance follows the overridden unit
+	info := &smartCtlInfo{DeviceModel: "INTEL SSDSC2BW480H6", FirmwareVersion: "RG20", LogicalSectorSizes: 512}
+	endurance := newSSDEndurance(info, []*smartAttribute{{ID: 241, Value: 100, RawValue: 1000}}, db)
+	assert.Equal(t, float64(1000)*1024*1024*1024, endurance.BytesWritten)
+}
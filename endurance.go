@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ssdEndurance struct {
+	Vendor       string
+	BytesWritten float64
+	HasWritten   bool
+	LifeUsedPct  int
+	HasLifeUsed  bool
+}
+
+// newSSDEndurance reads host writes and wear from the attributes the drive
+// database marks as such for the drive's model.
+func newSSDEndurance(info *smartCtlInfo, attrs []*smartAttribute, drives driveDB) *ssdEndurance {
+	model, firmware := info.modelAndFirmware()
+	known := drives.lookup(model, firmware)
+	vendor, written, life := drives.enduranceAttributes(model, firmware)
+	byID := make(map[int]*smartAttribute, len(attrs))
+	for _, attr := range attrs {
+		byID[attr.ID] = attr
+	}
+
+	endurance := &ssdEndurance{Vendor: vendor}
+	sectorSize := float64(info.LogicalSectorSizes)
+	if sectorSize == 0 {
+		sectorSize = 512
+	}
+	for _, id := range written {
+		if _, ok := known[id]; !ok {
+			continue
+		}
+		if attr, ok := byID[id]; ok {
+			endurance.BytesWritten = known[id].value(attr)
+			if known[id].Unit == "sectors" {
+				endurance.BytesWritten *= sectorSize
+			}
+			endurance.HasWritten = true
+			break
+		}
+	}
+	for _, id := range life {
+		if _, ok := known[id]; !ok {
+			continue
+		}
+		if attr, ok := byID[id]; ok {
+			endurance.LifeUsedPct = 100 - attr.Value
+			endurance.HasLifeUsed = true
+			break
+		}
+	}
+	return endurance
+}
+
+// fillFromDeviceStatistics uses the vendor neutral Device Statistics log for
+// drives whose vendor attributes are unknown or missing.
+func (endurance *ssdEndurance) fillFromDeviceStatistics(info *smartCtlInfo, stats []*deviceStatistic) {
+	if endurance.Vendor == "" || !endurance.HasWritten {
+		if sectors, ok := lookupDeviceStatistic(stats, "logical_sectors_written"); ok {
+			sectorSize := info.LogicalSectorSizes
+			if sectorSize == 0 {
+				sectorSize = 512
+			}
+			endurance.BytesWritten = float64(sectors) * float64(sectorSize)
+			endurance.HasWritten = true
+		}
+	}
+	if endurance.Vendor == "" || !endurance.HasLifeUsed {
+		if used, ok := lookupDeviceStatistic(stats, "percentage_used_endurance_indicator"); ok {
+			endurance.LifeUsedPct = int(used)
+			endurance.HasLifeUsed = true
+		}
+	}
+}
+
+// enduranceSample is the amount of host writes seen at a point in time.
+type enduranceSample struct {
+	Time         int64
+	BytesWritten float64
+}
+
+// daysToRatedTBW extrapolates the write rate observed since base to find when
+// ratedTB terabytes will have been written. It also returns the write rate in
+// bytes per day, and false if there is not enough history to tell.
+func daysToRatedTBW(ratedTB float64, written float64, base enduranceSample, now time.Time) (float64, float64, bool) {
+	elapsed := now.Sub(time.Unix(base.Time, 0))
+	if elapsed < time.Hour || written < base.BytesWritten {
+		return 0, 0, false
+	}
+	perDay := (written - base.BytesWritten) / elapsed.Hours() * 24
+	left := ratedTB*1e12 - written
+	if left <= 0 {
+		return 0, perDay, true
+	}
+	if perDay <= 0 {
+		return 0, perDay, false
+	}
+	return left / perDay, perDay, true
+}
+
+// ratedTBW is the rated endurance of the SSD models matching a regular
+// expression.
+type ratedTBW struct {
+	modelRgx *regexp.Regexp
+	TB       float64
+}
+
+type ratedTBWList []ratedTBW
+
+// parseRatedTBW compiles MODEL=TBW flag values, keeping their order.
+func parseRatedTBW(values []string) (ratedTBWList, error) {
+	list := ratedTBWList{}
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("rated TBW %q: expected MODEL=TBW", value)
+		}
+		rgx, err := regexp.Compile(value[:i])
+		if err != nil {
+			return nil, err
+		}
+		tb, err := strconv.ParseFloat(value[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("rated TBW %q: %v", value, err)
+		}
+		list = append(list, ratedTBW{modelRgx: rgx, TB: tb})
+	}
+	return list, nil
+}
+
+// lookup returns the rated endurance of the first entry matching model.
+func (list ratedTBWList) lookup(model string) (float64, bool) {
+	for _, rated := range list {
+		if rated.modelRgx.MatchString(model) {
+			return rated.TB, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntelSSDEndurance(t *testing.T) {
+	info := &smartCtlInfo{DeviceModel: "INTEL SSDSC2BW480H6", LogicalSectorSizes: 512, IsSSD: true}
+	attrs := []*smartAttribute{
+		{ID: 225, Value: 100, RawValue: 1726241},
+		{ID: 233, Value: 74, RawValue: 0},
+		{ID: 241, Value: 100, RawValue: 1726241},
+	}
+	endurance := newSSDEndurance(info, attrs, defaultDriveDB)
+	assert.Equal(t, "Intel", endurance.Vendor)
+	assert.True(t, endurance.HasWritten)
+	assert.Equal(t, float64(1726241)*32*1024*1024, endurance.BytesWritten)
+	assert.True(t, endurance.HasLifeUsed)
+	assert.Equal(t, 26, endurance.LifeUsedPct)
+}
+
+func TestSSDEnduranceUnits(t *testing.T) {
+	samsung := newSSDEndurance(
+		&smartCtlInfo{DeviceModel: "Samsung SSD 850 PRO 512GB", LogicalSectorSizes: 512},
+		[]*smartAttribute{{ID: 177, Value: 95, RawValue: 112}, {ID: 241, Value: 99, RawValue: 60000000000}}, defaultDriveDB)
+	assert.Equal(t, "Samsung", samsung.Vendor)
+	assert.Equal(t, float64(60000000000)*512, samsung.BytesWritten)
+	assert.Equal(t, 5, samsung.LifeUsedPct)
+
+	crucial := newSSDEndurance(
+		&smartCtlInfo{DeviceModel: "CT500MX500SSD1", LogicalSectorSizes: 512},
+		[]*smartAttribute{{ID: 202, Value: 97, RawValue: 3}, {ID: 246, Value: 100, RawValue: 20000000000}}, defaultDriveDB)
+	assert.Equal(t, "Crucial", crucial.Vendor)
+	assert.Equal(t, float64(20000000000)*512, crucial.BytesWritten)
+	assert.Equal(t, 3, crucial.LifeUsedPct)
+
+	kingston := newSSDEndurance(
+		&smartCtlInfo{DeviceModel: "KINGSTON SA400S37240G", LogicalSectorSizes: 512},
+		[]*smartAttribute{{ID: 231, Value: 90, RawValue: 90}, {ID: 241, Value: 100, RawValue: 8000}}, defaultDriveDB)
+	assert.Equal(t, "Kingston", kingston.Vendor)
+	assert.Equal(t, float64(8000)*1024*1024*1024, kingston.BytesWritten)
+	assert.Equal(t, 10, kingston.LifeUsedPct)
+
+	sandisk := newSSDEndurance(
+		&smartCtlInfo{DeviceModel: "SanDisk SDSSDH3500G", LogicalSectorSizes: 512},
+		[]*smartAttribute{{ID: 230, Value: 96, RawValue: 1}, {ID: 232, Value: 100, RawValue: 100}, {ID: 241, Value: 100, RawValue: 2000}}, defaultDriveDB)
+	assert.Equal(t, "SanDisk", sandisk.Vendor)
+	assert.Equal(t, float64(2000)*1024*1024*1024, sandisk.BytesWritten)
+	assert.Equal(t, 4, sandisk.LifeUsedPct)
+
+	// reserved space is not wear
+	sandisk = newSSDEndurance(
+		&smartCtlInfo{DeviceModel: "SanDisk SDSSDH3500G", LogicalSectorSizes: 512},
+		[]*smartAttribute{{ID: 232, Value: 100, RawValue: 100}}, defaultDriveDB)
+	assert.False(t, sandisk.HasLifeUsed)
+
+	unknown := newSSDEndurance(
+		&smartCtlInfo{DeviceModel: "SOME SSD", LogicalSectorSizes: 4096},
+		[]*smartAttribute{{ID: 241, Value: 100, RawValue: 1000}}, defaultDriveDB)
+	assert.Equal(t, "", unknown.Vendor)
+	assert.Equal(t, float64(1000)*4096, unknown.BytesWritten)
+	assert.False(t, unknown.HasLifeUsed)
+}
+
+func TestDaysToRatedTBW(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	base := enduranceSample{Time: now.Add(-10 * 24 * time.Hour).Unix(), BytesWritten: 100e12}
+
+	days, perDay, ok := daysToRatedTBW(288, 110e12, base, now)
+	assert.True(t, ok)
+	assert.InDelta(t, 1e12, perDay, 1)
+	assert.InDelta(t, 178, days, 0.001)
+
+	days, _, ok = daysToRatedTBW(100, 110e12, base, now)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, days)
+
+	_, _, ok = daysToRatedTBW(288, 110e12, enduranceSample{Time: now.Unix(), BytesWritten: 100e12}, now)
+	assert.False(t, ok)
+}
+
+func TestParseRatedTBW(t *testing.T) {
+	list, err := parseRatedTBW([]string{"INTEL SSDSC2BW480H6=288", "INTEL SSDSC2=150", "Samsung SSD 8[56]0 (PRO|EVO)=300.5"})
+	assert.NoError(t, err)
+
+	tb, ok := list.lookup("INTEL SSDSC2BW480H6")
+	assert.True(t, ok)
+	assert.Equal(t, 288.0, tb)
+	tb, ok = list.lookup("INTEL SSDSC2BB240G4")
+	assert.True(t, ok)
+	assert.Equal(t, 150.0, tb)
+	tb, ok = list.lookup("Samsung SSD 850 EVO 500GB")
+	assert.True(t, ok)
+	assert.Equal(t, 300.5, tb)
+	_, ok = list.lookup("Crucial_CT500MX200SSD1")
+	assert.False(t, ok)
+
+	_, err = parseRatedTBW([]string{"INTEL SSDSC2BW480H6"})
+	assert.Error(t, err)
+	_, err = parseRatedTBW([]string{"INTEL (=288"})
+	assert.Error(t, err)
+	_, err = parseRatedTBW([]string{"INTEL=lots"})
+	assert.Error(t, err)
+}
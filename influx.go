@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// point is a single InfluxDB line protocol entry.
+type point struct {
+	Measurement string
+	Tags        []string
+	Fields      []string
+}
+
+func (p point) String() string {
+	return fmt.Sprintf("%s,%s %s", p.Measurement, strings.Join(p.Tags, ","), strings.Join(p.Fields, ","))
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func tag(key, value string) string {
+	return fmt.Sprintf("%s=%s", key, tagEscaper.Replace(value))
+}
+
+var fieldStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func field(key string, value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf(`%s="%s"`, key, fieldStringEscaper.Replace(v))
+	case float64:
+		return fmt.Sprintf("%s=%s", key, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return fmt.Sprintf("%s=%v", key, value)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPointString(t *testing.T) {
+	p := point{
+		Measurement: "disk-health-checker",
+		Tags:        []string{tag("host", "db01"), tag("vdev", "mirror 0,a=b")},
+		Fields:      []string{field("state", `say "hi"`), field("read_errors", int64(3)), field("ssd", true), field("written", 5.792461651968e+13)},
+	}
+	assert.Equal(t, `disk-health-checker,host=db01,vdev=mirror\ 0\,a\=b state="say \"hi\"",read_errors=3,ssd=true,written=57924616519680`, p.String())
+}
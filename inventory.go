@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+type inventoryRecord struct {
+	Host               string  `json:"host"`
+	Disk               string  `json:"disk"`
+	Type               string  `json:"type"`
+	Model              string  `json:"model"`
+	Vendor             string  `json:"vendor"`
+	Serial             string  `json:"serial"`
+	WWN                string  `json:"wwn"`
+	Firmware           string  `json:"firmware"`
+	CapacityBytes      int64   `json:"capacity_bytes"`
+	LogicalSectorSize  int     `json:"logical_sector_size"`
+	PhysicalSectorSize int     `json:"physical_sector_size"`
+	RotationRate       string  `json:"rotation_rate"`
+	InterfaceSpeedGbps float64 `json:"interface_speed_gbps"`
+	PowerOnHours       int     `json:"power_on_hours"`
+}
+
+var inventoryCSVHeader = []string{
+	"host", "disk", "type", "model", "vendor", "serial", "wwn", "firmware", "capacity_bytes",
+	"logical_sector_size", "physical_sector_size", "rotation_rate", "interface_speed_gbps", "power_on_hours",
+}
+
+var (
+	leadingIntegerRgx   = regexp.MustCompile(`^\d+`)
+	scsiPowerOnTimeRgx  = regexp.MustCompile(`(?m)^\s*Accumulated power on time, hours:minutes (\d+):\d+`)
+	scsiHoursPoweredRgx = regexp.MustCompile(`(?m)^\s*number of hours powered up = (\d+)`)
+)
+
+// powerOnHours reads attribute 9's raw value, which some drives write as a
+// duration or followed by vendor details, e.g. 14605h+12m+03.456s.
+func powerOnHours(attr *smartAttribute) int {
+	if m := durationRawValueRgx.FindStringSubmatch(attr.RawString); m != nil {
+		hours, _ := strconv.Atoi(m[1])
+		return hours
+	}
+	hours, _ := strconv.Atoi(leadingIntegerRgx.FindString(attr.RawString))
+	if hours == 0 {
+		return int(attr.RawValue)
+	}
+	return hours
+}
+
+// scsiPowerOnHours reads the accumulated power on time SCSI disks report
+// instead of attribute 9.
+func scsiPowerOnHours(out string) (int, bool) {
+	for _, rgx := range []*regexp.Regexp{scsiPowerOnTimeRgx, scsiHoursPoweredRgx} {
+		if m := rgx.FindStringSubmatch(out); m != nil {
+			hours, _ := strconv.Atoi(m[1])
+			return hours, true
+		}
+	}
+	return 0, false
+}
+
+// newInventoryRecord describes a disk from its smartctl information, its
+// attributes and, for SCSI disks, the scsiattributes section.
+func newInventoryRecord(hostname string, device deviceInfo, info *smartCtlInfo, attrs []*smartAttribute, scsiAttributes string) inventoryRecord {
+	model, firmware := info.modelAndFirmware()
+	record := inventoryRecord{
+		Host:               hostname,
+		Disk:               device.Path,
+		Type:               device.Type,
+		Model:              model,
+		Vendor:             info.Vendor,
+		Serial:             info.SerialNumber,
+		WWN:                info.LUWWNDeviceID,
+		Firmware:           firmware,
+		CapacityBytes:      info.UserCapacityBytes,
+		LogicalSectorSize:  info.LogicalSectorSizes,
+		PhysicalSectorSize: info.PhysicalSectorSizes,
+		RotationRate:       info.RotationRate,
+		InterfaceSpeedGbps: info.SATACurrentSpeedGbps,
+	}
+	if record.WWN == "" {
+		record.WWN = info.LogicalUnitID
+	}
+	if record.InterfaceSpeedGbps == 0 {
+		record.InterfaceSpeedGbps = info.SATAMaxSpeedGbps
+	}
+	if record.LogicalSectorSize == 0 {
+		record.LogicalSectorSize = info.LogicalBlockSizeBytes
+	}
+	for _, attr := range attrs {
+		if attr.ID == 9 {
+			record.PowerOnHours = powerOnHours(attr)
+		}
+	}
+	if hours, ok := scsiPowerOnHours(scsiAttributes); ok {
+		record.PowerOnHours = hours
+	}
+	return record
+}
+
+func writeInventoryCSV(w io.Writer, records []inventoryRecord) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(inventoryCSVHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		err := out.Write([]string{
+			r.Host, r.Disk, r.Type, r.Model, r.Vendor, r.Serial, r.WWN, r.Firmware,
+			strconv.FormatInt(r.CapacityBytes, 10), strconv.Itoa(r.LogicalSectorSize),
+			strconv.Itoa(r.PhysicalSectorSize), r.RotationRate, strconv.FormatFloat(r.InterfaceSpeedGbps, 'f', -1, 64), strconv.Itoa(r.PowerOnHours),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeInventoryJSON(w io.Writer, records []inventoryRecord) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventoryRecord(t *testing.T) {
+	info := &smartCtlInfo{
+		DeviceModel:          "HGST HDN724040ALE640",
+		SerialNumber:         "PK2338P4H4XPXC",
+		LUWWNDeviceID:        "5 000cca 249d054c0",
+		FirmwareVersion:      "MJAOA5E0",
+		UserCapacityBytes:    4000787030016,
+		LogicalSectorSizes:   512,
+		PhysicalSectorSizes:  4096,
+		RotationRate:         "7200 rpm",
+		SATAVersion:          "SATA 3.0, 6.0 Gb/s (current: 3.0 Gb/s)",
+		SATAMaxSpeedGbps:     6,
+		SATACurrentSpeedGbps: 3,
+	}
+	attrs := []*smartAttribute{{ID: 5}, {ID: 9, RawValue: 14605}}
+	records := []inventoryRecord{
+		newInventoryRecord("db01", deviceInfo{Path: "/dev/sda", Type: "auto"}, info, attrs, ""),
+		newInventoryRecord("db01", deviceInfo{Path: "/dev/sdb", Type: "auto"}, &smartCtlInfo{
+			Vendor:                "LSI",
+			Product:               "Logical Volume",
+			Revision:              "3000",
+			UserCapacityBytes:     298999349248,
+			LogicalBlockSizeBytes: 512,
+			LogicalUnitID:         "0x600508e0000000006b402971cbb20d0f",
+		}, nil, ""),
+	}
+	assert.Equal(t, 14605, records[0].PowerOnHours)
+	assert.Equal(t, "Logical Volume", records[1].Model)
+	assert.Equal(t, "3000", records[1].Firmware)
+	assert.Equal(t, "0x600508e0000000006b402971cbb20d0f", records[1].WWN)
+	assert.Equal(t, 512, records[1].LogicalSectorSize)
+
+	var out bytes.Buffer
+	assert.NoError(t, writeInventoryCSV(&out, records))
+	assert.Equal(t, `host,disk,type,model,vendor,serial,wwn,firmware,capacity_bytes,logical_sector_size,physical_sector_size,rotation_rate,interface_speed_gbps,power_on_hours
+db01,/dev/sda,auto,HGST HDN724040ALE640,,PK2338P4H4XPXC,5 000cca 249d054c0,MJAOA5E0,4000787030016,512,4096,7200 rpm,3,14605
+db01,/dev/sdb,auto,Logical Volume,LSI,,0x600508e0000000006b402971cbb20d0f,3000,298999349248,512,0,,0,0
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, writeInventoryJSON(&out, records[:1]))
+	assert.Contains(t, out.String(), `"serial": "PK2338P4H4XPXC"`)
+	assert.Contains(t, out.String(), `"capacity_bytes": 4000787030016`)
+}
+
+func TestInventoryPowerOnHours(t *testing.T) {
+	for raw, hours := range map[string]int{
+		"14605":              14605,
+		"14605h+12m+03.456s": 14605,
+		"8739 (207 132 0)":   8739,
+		"36385 (88 153 0)":   36385,
+		"0":                  0,
+	} {
+		attrs := []*smartAttribute{{ID: 9, RawString: raw}}
+		record := newInventoryRecord("db01", deviceInfo{Path: "/dev/sda"}, &smartCtlInfo{}, attrs, "")
+		assert.Equal(t, hours, record.PowerOnHours, raw)
+	}
+
+	record := newInventoryRecord("db01", deviceInfo{Path: "/dev/sdc"}, &smartCtlInfo{Vendor: "SEAGATE"}, nil, `Current Drive Temperature:     31 C
+Drive Trip Temperature:        68 C
+
+Accumulated power on time, hours:minutes 41619:47
+Manufactured in week 14 of year 2016
+Elements in grown defect list: 0
+`)
+	assert.Equal(t, 41619, record.PowerOnHours)
+
+	record = newInventoryRecord("db01", deviceInfo{Path: "/dev/sdd"}, &smartCtlInfo{Vendor: "SEAGATE"}, nil, `Elements in grown defect list: 0
+
+Vendor (Seagate) factory information
+  number of hours powered up = 25102.73
+  number of minutes until next internal SMART test = 42
+`)
+	assert.Equal(t, 25102, record.PowerOnHours)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type kernelErrorCounts struct {
+	IOErrors     int
+	LinkResets   int
+	Timeouts     int
+	MediumErrors int
+}
+
+var (
+	kmsgRecordRgx      = regexp.MustCompile(`^\d+,(\d+),\d+,[^;]*;(.*)$`)
+	kmsgSCSIDiskRgx    = regexp.MustCompile(`\[(\w+)\]`)
+	kmsgBlockDevRgx    = regexp.MustCompile(`\bdev (\w+)`)
+	kmsgATAPortRgx     = regexp.MustCompile(`^(ata\d+)(?:\.\d+)?:`)
+	kmsgMediumErrorRgx = regexp.MustCompile(`(?i)medium error|unrecovered read error`)
+	// only the block layer's report, not the "Buffer I/O error on dev" the
+	// page cache logs for the same failed request
+	kmsgIOErrorRgx   = regexp.MustCompile(`I/O error, dev \w+`)
+	kmsgLinkResetRgx = regexp.MustCompile(`(?i)(hard|soft) resetting link|COMRESET failed|SATA link down|limiting SATA link speed|link is slow to respond`)
+	kmsgTimeoutRgx   = regexp.MustCompile(`(?i)\btimeout\b|timed out|timing out`)
+	sysATAPortRgx    = regexp.MustCompile(`/(ata\d+)/`)
+)
+
+// countKernelErrors classifies the /dev/kmsg records newer than lastSeq by
+// kernel device name, mapping ATA ports to disks through ataDisks. It returns
+// the counts and the sequence number of the last record read. A lastSeq of -1
+// counts every record.
+func countKernelErrors(out string, lastSeq int64, ataDisks map[string]string) (map[string]*kernelErrorCounts, int64) {
+	type record struct {
+		seq     int64
+		message string
+	}
+	records := []record{}
+	maxSeq := int64(-1)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		m := kmsgRecordRgx.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		seq, _ := strconv.ParseInt(m[1], 10, 64)
+		if seq > maxSeq {
+			maxSeq = seq
+		}
+		// the lines of a multi-line message are joined by an escaped newline
+		records = append(records, record{seq, strings.Replace(m[2], `\x0a`, "\n", -1)})
+	}
+	if len(records) == 0 {
+		return map[string]*kernelErrorCounts{}, lastSeq
+	}
+
+	// a sequence number going backwards means the machine rebooted since the
+	// previous run, so everything in the buffer is new
+	if maxSeq < lastSeq {
+		lastSeq = -1
+	}
+
+	counts := map[string]*kernelErrorCounts{}
+	for _, r := range records {
+		if r.seq <= lastSeq {
+			continue
+		}
+		disk := kernelMessageDisk(r.message, ataDisks)
+		if disk == "" {
+			continue
+		}
+		c := counts[disk]
+		if c == nil {
+			c = &kernelErrorCounts{}
+		}
+		switch {
+		case kmsgMediumErrorRgx.MatchString(r.message):
+			c.MediumErrors++
+		case kmsgIOErrorRgx.MatchString(r.message):
+			c.IOErrors++
+		case kmsgLinkResetRgx.MatchString(r.message):
+			c.LinkResets++
+		case kmsgTimeoutRgx.MatchString(r.message):
+			c.Timeouts++
+		default:
+			continue
+		}
+		counts[disk] = c
+	}
+	return counts, maxSeq
+}
+
+func kernelMessageDisk(message string, ataDisks map[string]string) string {
+	if m := kmsgATAPortRgx.FindStringSubmatch(message); m != nil {
+		return ataDisks[m[1]]
+	}
+	if m := kmsgBlockDevRgx.FindStringSubmatch(message); m != nil {
+		return strings.TrimPrefix(parentDisk("/dev/"+m[1]), "/dev/")
+	}
+	if m := kmsgSCSIDiskRgx.FindStringSubmatch(message); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// ataPortDisks maps libata port names such as ata3 to the kernel name of the
+// disk attached to them, using the sysfs device hierarchy.
+func ataPortDisks(names []string) map[string]string {
+	ports := map[string]string{}
+	for _, name := range names {
+		path, err := filepath.EvalSymlinks(filepath.Join("/sys/block", name, "device"))
+		if err != nil {
+			continue
+		}
+		if m := sysATAPortRgx.FindStringSubmatch(path); m != nil {
+			ports[m[1]] = name
+		}
+	}
+	return ports
+}
+
+// readKernelLog reads all records currently buffered in /dev/kmsg, or the
+// whole content of a regular file in the same format, without blocking.
+func readKernelLog(path string) (string, error) {
+	if !strings.HasPrefix(path, "/dev/") {
+		data, err := ioutil.ReadFile(path)
+		return string(data), err
+	}
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.Close(fd)
+
+	var out bytes.Buffer
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(fd, buf)
+		switch {
+		case err == syscall.EAGAIN:
+			return out.String(), nil
+		case err == syscall.EPIPE:
+			// records were overwritten while reading, carry on with the next one
+			continue
+		case err != nil:
+			return out.String(), err
+		case n == 0:
+			return out.String(), nil
+		}
+		out.Write(buf[:n])
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var kmsgOutput = `
+6,1021,5312345678,-;sd 2:0:0:0: [sdb] 7814037168 512-byte logical blocks: (4.00 TB/3.64 TiB)
+3,1022,5312400001,-;ata3.00: exception Emask 0x0 SAct 0x40000 SErr 0x0 action 0x6 frozen
+3,1023,5312400002,-;ata3.00: cmd 60/08:90:c8:0e:2b/00:00:00:00:00/40 tag 18 ncq dma 4096 in\x0a         res 40/00:00:00:00:00/00:00:00:00:00/00 Emask 0x4 (timeout)
+ SUBSYSTEM=scsi
+ DEVICE=+scsi:2:0:0:0
+3,1024,5312400004,-;ata3.00: status: { DRDY }
+6,1025,5312400005,-;ata3: hard resetting link
+6,1026,5312400006,-;ata3: SATA link up 6.0 Gbps (SStatus 133 SControl 300)
+3,1027,5312400007,-;sd 2:0:0:0: [sdb] tag#18 Sense Key : Medium Error [current]
+3,1028,5312400008,-;blk_update_request: I/O error, dev sdb, sector 2822344 op 0x0:(READ) flags 0x0 phys_seg 1 prio class 0
+3,1029,5312400009,-;Buffer I/O error on dev sdb1, logical block 352537, async page read
+3,1030,5312400010,-;sd 0:0:0:0: [sda] tag#2 timing out command, waited 180s
+3,1031,5312400011,-;ata4: COMRESET failed (errno=-16)
+`
+
+func TestCountKernelErrors(t *testing.T) {
+	counts, seq := countKernelErrors(kmsgOutput, -1, map[string]string{"ata3": "sdb"})
+	assert.Equal(t, int64(1031), seq)
+	assert.Len(t, counts, 2)
+	assert.Equal(t, 1, counts["sdb"].IOErrors)
+	assert.Equal(t, 1, counts["sdb"].LinkResets)
+	assert.Equal(t, 1, counts["sdb"].Timeouts)
+	assert.Equal(t, 1, counts["sdb"].MediumErrors)
+	assert.Equal(t, 1, counts["sda"].Timeouts)
+}
+
+func TestCountKernelErrorsSinceLastRun(t *testing.T) {
+	counts, seq := countKernelErrors(kmsgOutput, 1027, map[string]string{"ata3": "sdb"})
+	assert.Equal(t, int64(1031), seq)
+	assert.Equal(t, 1, counts["sdb"].IOErrors)
+	assert.Equal(t, 0, counts["sdb"].LinkResets)
+	assert.Equal(t, 0, counts["sdb"].MediumErrors)
+
+	counts, seq = countKernelErrors(kmsgOutput, 1031, nil)
+	assert.Equal(t, int64(1031), seq)
+	assert.Empty(t, counts)
+}
+
+func TestCountKernelErrorsAfterReboot(t *testing.T) {
+	counts, seq := countKernelErrors(kmsgOutput, 98765, map[string]string{"ata3": "sdb"})
+	assert.Equal(t, int64(1031), seq)
+	assert.Equal(t, 1, counts["sdb"].MediumErrors)
+	assert.Equal(t, 1, counts["sda"].Timeouts)
+}
@@ -4,33 +4,102 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"log/syslog"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
-	appName   = path.Base(os.Args[0])
-	app       = kingpin.New(appName, "A command-line checker for Disk Health checks using smartctl, by CrossEngage")
-	checkName = app.Flag("name", "check name").Default(appName).String()
-	debug     = app.Flag("debug", "if set, enables debug logs").Default("false").Bool()
-	stderr    = app.Flag("stderr", "if set, enables logging to stderr instead of syslog").Default("false").Bool()
-	smartCtl  = app.Flag("smartctl", "Path of smartctl").Default("/usr/sbin/smartctl").String()
+	appName          = path.Base(os.Args[0])
+	app              = kingpin.New(appName, "A command-line checker for Disk Health checks using smartctl, by CrossEngage")
+	checkName        = app.Flag("name", "check name").Default(appName).String()
+	debug            = app.Flag("debug", "if set, enables debug logs").Default("false").Bool()
+	stderr           = app.Flag("stderr", "if set, enables logging to stderr instead of syslog").Default("false").Bool()
+	smartCtl         = app.Flag("smartctl", "Path of smartctl").Default("/usr/sbin/smartctl").String()
+	privilegeMode    = app.Flag("privilege-mode", "How privileged commands are run: direct, needing root, or sudo, dropping root privileges if any").Default("direct").Enum("direct", "sudo")
+	sudoCmd          = app.Flag("sudo", "Path of sudo").Default("/usr/bin/sudo").String()
+	runAs            = app.Flag("run-as", "User to run as when started by root in sudo privilege mode").Default("nobody").String()
+	cciss            = app.Flag("cciss", "Device path of an HP Smart Array controller whose disks are probed as -d cciss,N, can be repeated").Strings()
+	aacraid          = app.Flag("aacraid", "Device path of an Adaptec controller, and its host and LUN, whose disks are probed as -d aacraid,H,L,N, e.g. /dev/sda=0,0").PlaceHolder("PATH=H,L").StringMap()
+	classActionFlags = app.Flag("class-action", "What to do with each class of device (physical, raid_volume, virtual, removable): report, skip, or resolve to its members, e.g. raid_volume=report").PlaceHolder("CLASS=ACTION").StringMap()
+	probeMax         = app.Flag("probe-max", "Number of disk indexes probed on each --cciss and --aacraid controller").Default("32").Int()
+	zfs              = app.Flag("zfs", "if set, reports ZFS pool and vdev error counters").Default("false").Bool()
+	zpoolCmd         = app.Flag("zpool", "Path of zpool").Default("/sbin/zpool").String()
+	btrfs            = app.Flag("btrfs", "if set, reports btrfs device error statistics").Default("false").Bool()
+	btrfsCmd         = app.Flag("btrfs-cmd", "Path of btrfs").Default("/bin/btrfs").String()
+	megaraid         = app.Flag("megaraid", "if set, reports MegaRAID controller, virtual drive and physical drive health from storcli").Default("false").Bool()
+	storcliCmd       = app.Flag("storcli", "Path of storcli, or of perccli").Default("/opt/MegaRAID/storcli/storcli64").String()
+	mounts           = app.Flag("mounts", "Path of the mounted filesystems table").Default("/proc/mounts").String()
+	kernelLog        = app.Flag("kernel-log", "if set, reports kernel I/O errors, link resets, timeouts and medium errors per disk").Default("false").Bool()
+	kmsg             = app.Flag("kmsg", "Path of the kernel message buffer, or of a file in the same format").Default("/dev/kmsg").String()
+	diskStat         = app.Flag("diskstats", "if set, reports I/O counters and average latency from the kernel's disk statistics").Default("false").Bool()
+	diskStatsPath    = app.Flag("diskstats-path", "Path of the kernel's disk statistics").Default("/proc/diskstats").String()
+	slowDiskGroup    = app.Flag("slow-disk-group", "Peer group each disk's latency is compared against: model, array or host").Default("array").Enum("model", "array", "host")
+	slowDiskRatio    = app.Flag("slow-disk-ratio", "Ratio to the peer group's median await above which a disk is flagged as slow").Default("3").Float64()
+	slowDiskMinAwait = app.Flag("slow-disk-min-await", "Average await in milliseconds below which a disk is never flagged as slow").Default("5").Float64()
+	ssdRatedTBW      = app.Flag("ssd-rated-tbw", "Rated endurance in TB written of SSD models matching a regular expression, e.g. 'INTEL SSDSC2BW480H6=288'; the first match applies").PlaceHolder("MODEL=TBW").Strings()
+	sataPhy          = app.Flag("sataphy", "if set, reports the SATA Phy event counters of SATA disks").Default("false").Bool()
+	sctTemp          = app.Flag("scttemp", "if set, reports the SCT temperature status and history of ATA disks").Default("false").Bool()
+	tempWarning      = app.Flag("temp-warning", "Temperature in Celsius from which a disk is reported as warning, defaults to the drive's recommended maximum").Default("0").Int()
+	tempCritical     = app.Flag("temp-critical", "Temperature in Celsius from which a disk is reported as critical, defaults to the drive's temperature limit").Default("0").Int()
+	devStat          = app.Flag("devstat", "if set, reports the Device Statistics log of ATA disks").Default("false").Bool()
+	bgScan           = app.Flag("background-scan", "if set, reports the background medium scan status and results of SAS disks").Default("false").Bool()
+	stateFile        = app.Flag("state-file", "Path of the file keeping values between runs").Default(path.Join("/var/lib", appName, "state.json")).String()
 
 	// https://en.wikipedia.org/wiki/S.M.A.R.T.#Known_ATA_S.M.A.R.T._attributes
-	attrIDs = app.Flag("attrs", "SMART Attribute IDs to return").Default(
+	attrIDs = app.Flag("attrs", "SMART Attribute IDs or canonical names to return").Default(
 		"1", "2", "3", "5", "7", "8", "9", "10", "12", "171", "172", "173",
-		"174", "190", "194", "197", "198", "199", "231", "233").Ints()
+		"174", "190", "194", "197", "198", "199", "231", "233").Strings()
+	semanticNames = app.Flag("semantic-names", "if set, names attributes by their canonical name, e.g. reallocated_sectors, instead of their ID").Default("false").Bool()
+	driveDBFile   = app.Flag("drivedb", "Path of a JSON drive database extending and overriding the built-in one").String()
+	advisoryFile  = app.Flag("advisories", "Path of a JSON list of firmware advisories extending the built-in one").String()
+	overridesFile = app.Flag("overrides", "Path of a JSON list of per-device smartctl type and argument overrides").String()
+
+	checkCommand     = app.Command("check", "Prints the health of every disk in InfluxDB line protocol").Default()
+	inventoryCommand = app.Command("inventory", "Prints an inventory record of every disk")
+	inventoryFormat  = inventoryCommand.Flag("format", "Output format: csv or json").Default("csv").Enum("csv", "json")
+	doctorCommand    = app.Command("doctor", "Prints the lines of every disk's smartctl output that were not recognised")
+	selfTestCommand  = app.Command("selftest", "Starts the SMART self-tests that are due and prints their progress in InfluxDB line protocol")
+	selfTestShort    = selfTestCommand.Flag("short-every", "Interval between short self-tests of each disk, 0 to disable").Default("24h").Duration()
+	selfTestLong     = selfTestCommand.Flag("long-every", "Interval between long self-tests of each disk, 0 to disable").Default("720h").Duration()
+	selfTestMax      = selfTestCommand.Flag("max-running", "Number of disks of the host allowed to run a self-test at the same time").Default("1").Int()
+)
+
+var (
+	drives       driveDB
+	advisories   advisoryDB
+	classActions = map[string]string{}
+	ratedTBWs    ratedTBWList
+	overrides    overrideDB
 )
 
 func main() {
+	// run by sudo as root, before any flag can have a say
+	if len(os.Args) > 1 && os.Args[1] == privilegedExecCommand {
+		if err := privilegedExec(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	// a setuid checker parses its arguments as the user who ran it, and
+	// only gets root back for the direct privilege mode
+	suspended, err := suspendPrivileges()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	app.Version(version)
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	command := kingpin.MustParse(app.Parse(os.Args[1:]))
 
 	if *stderr {
 		log.SetOutput(os.Stderr)
@@ -43,6 +112,50 @@ func main() {
 
 	}
 
+	if err := resumePrivileges(suspended); err != nil {
+		log.Fatal(err)
+	}
+	if *privilegeMode == "sudo" {
+		if err := dropPrivileges(*runAs); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	drives = defaultDriveDB
+	if *driveDBFile != "" {
+		extra, err := loadDriveDB(*driveDBFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		drives = append(drives, extra...)
+	}
+
+	if ratedTBWs, err = parseRatedTBW(*ssdRatedTBW); err != nil {
+		log.Fatal(err)
+	}
+
+	advisories = defaultAdvisoryDB
+	if *advisoryFile != "" {
+		extra, err := loadAdvisoryDB(*advisoryFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		advisories = append(advisories, extra...)
+	}
+
+	for class, action := range defaultClassActions {
+		classActions[class] = action
+	}
+	for class, action := range *classActionFlags {
+		if _, ok := defaultClassActions[class]; !ok {
+			log.Fatalf("unknown device class %q", class)
+		}
+		if action != "report" && action != "skip" && (action != "resolve" || class != classRAIDVolume) {
+			log.Fatalf("unsupported action %q for device class %s", action, class)
+		}
+		classActions[class] = action
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		log.Fatal(err)
@@ -54,41 +167,601 @@ func main() {
 	}
 
 	devices := parseSMARTCtlScan(stdOut)
-	for _, device := range devices {
-		stdOut, _, err := smartctl(*debug, "-i", "-H", device.Path, "-d", device.Type)
+	for _, path := range *cciss {
+		if !validDevicePath(path) {
+			log.Fatalf("--cciss %s: not a device path", path)
+		}
+	}
+	for path, hostLUN := range *aacraid {
+		if !validDevicePath(path) || !validHostLUN(hostLUN) {
+			log.Fatalf("--aacraid %s=%s: not a device path and a host and LUN", path, hostLUN)
+		}
+	}
+	if len(*cciss) > 0 || len(*aacraid) > 0 {
+		devices = probeDevices(debugRunner, *smartCtl, devices, *cciss, *aacraid, *probeMax)
+	}
+	if *overridesFile != "" {
+		if overrides, err = loadOverrideDB(*overridesFile); err != nil {
+			log.Fatal(err)
+		}
+		state, err := loadState(*stateFile)
+		if err != nil {
+			log.Println(err)
+		}
+		devices = applyOverrides(debugRunner, *smartCtl, devices, overrides, state)
+		if err := state.save(); err != nil {
+			log.Println(err)
+		}
+		state.release()
+		if *debug {
+			for _, device := range devices {
+				log.Printf("%s: type %s, extra arguments %v", device.Path, device.Type, device.Args)
+			}
+		}
+	}
+
+	switch command {
+	case inventoryCommand.FullCommand():
+		inventory(hostname, devices)
+	case doctorCommand.FullCommand():
+		doctor(devices)
+	case selfTestCommand.FullCommand():
+		selfTest(hostname, devices)
+	case checkCommand.FullCommand():
+		check(hostname, devices)
+	}
+}
+
+func check(hostname string, devices []deviceInfo) {
+	state, err := loadState(*stateFile)
+	if err != nil {
+		log.Println(err)
+	}
+	defer state.release()
+
+	var kernelErrors map[string]*kernelErrorCounts
+	if *kernelLog {
+		kernelErrors = collectKernelErrors(devices, state)
+	}
+
+	var ioStats map[string]*diskStats
+	if *diskStat {
+		out, err := ioutil.ReadFile(*diskStatsPath)
 		if err != nil {
 			log.Println(err)
 		}
+		ioStats = parseDiskStats(string(out))
+	}
+
+	reports := make([]*diskReport, 0, len(devices))
+	// devices grows as RAID volumes are resolved to their members
+	for i := 0; i < len(devices); i++ {
+		report := collectDisk(devices[i], hostname, state, kernelErrors, ioStats)
+		switch classActions[report.Class] {
+		case "report":
+			reports = append(reports, report)
+		case "resolve":
+			controller := scsiBusPath("/sys/block", kernelName(devices[i].Path))
+			members := resolveRAIDVolume(debugRunner, *smartCtl, devices[i], report.Info, devices, controller, *probeMax)
+			if len(members) == 0 && *debug {
+				log.Printf("%s: no members to resolve the %s to, skipping it", devices[i].Path, report.Class)
+			}
+			devices = append(devices, applyOverrides(debugRunner, *smartCtl, members, overrides, state)...)
+		}
+	}
+
+	points := []point{}
+	arrays := mdArrays("/sys/block")
 
-		info := parseSMARTCtlInfo(stdOut)
-		fmt.Printf("%s,host=%s,disk=%s,type=%s ", *checkName, hostname, device.Path, strings.Replace(device.Type, ",", "_", -1))
-		values := []string{fmt.Sprintf(`disk_status="%s"`, info.Health)}
+	if *zfs {
+		stdOut, _, err := privileged(*debug, *zpoolCmd, "status", "-p")
+		if err != nil {
+			log.Println(err)
+		}
+		pools := parseZpoolStatus(stdOut)
+		for disk, array := range zpoolArrays(pools, devices) {
+			arrays[disk] = array
+		}
+		points = append(points, zpoolPoints(pools, devices, hostname)...)
+	}
 
-		if info.SMARTSupport {
-			stdOut, _, err = smartctl(*debug, "-A", device.Path, "-d", device.Type)
+	if *btrfs {
+		table, err := ioutil.ReadFile(*mounts)
+		if err != nil {
+			log.Println(err)
+		}
+		for _, mount := range parseBtrfsMounts(string(table)) {
+			stdOut, _, err := privileged(*debug, *btrfsCmd, "device", "stats", mount)
 			if err != nil {
 				log.Println(err)
 			}
-			attrs := parseAttributeList(stdOut)
-			for _, attr := range attrs {
-				// TODO replace this by something more efficient
-				for _, id := range *attrIDs {
-					if attr.ID == id {
-						values = append(values, attr.String(true, false))
-						continue
+			stats := parseBtrfsDeviceStats(stdOut)
+			for _, stat := range stats {
+				if disk := findDisk(devices, stat.Device); disk != nil {
+					arrays[disk.Path] = "btrfs:" + mount
+				}
+			}
+			points = append(points, btrfsPoints(mount, stats, devices, hostname)...)
+		}
+	}
+
+	if *megaraid {
+		controllers, err := collectMegaRAID(debugRunner, *storcliCmd)
+		if err != nil {
+			log.Println(err)
+		}
+		points = append(points, megaraidPoints(controllers, devices, hostname)...)
+	}
+
+	scoreLatencyOutliers(reports, arrays)
+
+	coverages := make([]parserCoverage, 0, len(reports))
+	for _, report := range reports {
+		coverages = append(coverages, report.Coverage)
+	}
+	points = append(points, coveragePoints(coverages, hostname)...)
+
+	for _, report := range reports {
+		fmt.Println(report.Point)
+	}
+	for _, p := range points {
+		fmt.Println(p)
+	}
+
+	if err := state.save(); err != nil {
+		log.Println(err)
+	}
+}
+
+func inventory(hostname string, devices []deviceInfo) {
+	records := make([]inventoryRecord, 0, len(devices))
+	for _, device := range devices {
+		stdOut, _, err := smartctl(*debug, device.args("-i", "-A")...)
+		if err != nil {
+			log.Println(err)
+		}
+		sections := splitSmartctlSections(stdOut)
+		attrs, _ := parseAttributeList(sections["attributes"])
+		records = append(records, newInventoryRecord(hostname, device, parseSMARTCtlInfo(sections["info"]), attrs, sections["scsiattributes"]))
+	}
+
+	var err error
+	switch *inventoryFormat {
+	case "json":
+		err = writeInventoryJSON(os.Stdout, records)
+	default:
+		err = writeInventoryCSV(os.Stdout, records)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func selfTest(hostname string, devices []deviceInfo) {
+	// without a record of the tests started, every run would start them again
+	state, err := loadState(*stateFile)
+	persistent := err == nil
+	if err != nil {
+		log.Printf("Not starting any self-test, the state cannot be loaded: %v", err)
+	}
+	defer state.release()
+
+	infos := make([]*smartCtlInfo, len(devices))
+	statuses := make([]*selfTestStatus, len(devices))
+	running := 0
+	for i, device := range devices {
+		stdOut, _, err := smartctl(*debug, device.args("-i", "-c")...)
+		if err != nil {
+			log.Println(err)
+		}
+		sections := splitSmartctlSections(stdOut)
+		infos[i] = parseSMARTCtlInfo(sections["info"])
+		statuses[i] = parseSelfTestStatus(sections["capabilities"])
+		if statuses[i] != nil && statuses[i].Running {
+			running++
+		}
+	}
+
+	now := time.Now()
+	for i, device := range devices {
+		status := statuses[i]
+		if status == nil {
+			continue
+		}
+		id := infos[i].SerialNumber
+		if id == "" {
+			id = device.Path
+		}
+		record := selfTestRecord{}
+		state.get("selftest:"+id, &record)
+
+		started := ""
+		if !status.Running {
+			record.Running = ""
+			test := record.due(hostname+" "+device.Path+" "+device.Type, *selfTestShort, *selfTestLong, now)
+			if test != "" && running < *selfTestMax && persistent {
+				// the start is saved before the test runs, so that it is not
+				// started again by every run when the state cannot be saved
+				previous := record
+				record.started(test, now)
+				state.set("selftest:"+id, record)
+				if err := state.save(); err != nil {
+					log.Printf("Not starting any self-test, the state cannot be saved: %v", err)
+					record = previous
+					persistent = false
+				} else if _, _, err := smartctl(*debug, device.args("-t", test)...); err != nil {
+					log.Println(err)
+					record = previous
+				} else {
+					log.Printf("Started a %s self-test on %s", test, device.Path)
+					status.Running, status.RemainingPct = true, 100
+					started = test
+					running++
+				}
+			}
+		}
+		state.set("selftest:"+id, record)
+
+		p := point{
+			Measurement: *checkName + "_selftest",
+			Tags:        []string{tag("host", hostname), tag("disk", device.Path), tag("type", strings.Replace(device.Type, ",", "_", -1))},
+			Fields: []string{
+				field("selftest_running", status.Running),
+				field("selftest_remaining_pct", status.RemainingPct),
+				field("selftest_status", status.Code),
+				field("selftest_test", record.Running),
+				field("selftest_started", started),
+				field("selftest_last_short", record.Short),
+				field("selftest_last_long", record.Long),
+			},
+		}
+		fmt.Println(p)
+	}
+
+	if err := state.save(); err != nil {
+		log.Println(err)
+	}
+}
+
+// collectOptions returns the smartctl options reading everything checked
+// about a disk, in a single run for a consistent view of it.
+func collectOptions() []string {
+	options := []string{"-i", "-H", "-A"}
+	if *devStat {
+		options = append(options, "-l", "devstat")
+	}
+	if *sctTemp {
+		options = append(options, "-l", "scttemp")
+	}
+	if *bgScan {
+		options = append(options, "-l", "background")
+	}
+	if *sataPhy {
+		options = append(options, "-l", "sataphy")
+	}
+	return options
+}
+
+func doctor(devices []deviceInfo) {
+	for _, device := range devices {
+		stdOut, _, err := smartctl(*debug, device.args(collectOptions()...)...)
+		if err != nil {
+			log.Println(err)
+		}
+		sections := splitSmartctlSections(stdOut)
+		info := parseSMARTCtlInfo(sections["info"] + sections["health"])
+		if err := writeDoctorReport(os.Stdout, device, info, sectionCoverage(sections)); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// diskReport is what was collected about a single disk during this run.
+type diskReport struct {
+	Device   deviceInfo
+	Info     *smartCtlInfo
+	Class    string
+	Coverage parserCoverage
+	Point    point
+	Await    float64
+	HasAwait bool
+}
+
+func collectDisk(device deviceInfo, hostname string, state *stateStore, kernelErrors map[string]*kernelErrorCounts, ioStats map[string]*diskStats) *diskReport {
+	options := collectOptions()
+	name := deviceKernelName(device)
+	var stdOut, bridgeType string
+	if isUSBDisk(name) {
+		device, stdOut, bridgeType = readUSBDisk(debugRunner, *smartCtl, state, device, options)
+	} else {
+		var err error
+		stdOut, _, err = smartctl(*debug, device.args(options...)...)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	sections := splitSmartctlSections(stdOut)
+	info := parseSMARTCtlInfo(sections["info"] + sections["health"])
+	if overrides.needsIdentity() {
+		rememberIdentity(state, device, info)
+	}
+
+	report := &diskReport{Device: device, Info: info}
+	report.Class = classifyDevice(device, info, isRemovable(name))
+	if classActions[report.Class] != "report" {
+		return report
+	}
+
+	p := &report.Point
+	p.Measurement = *checkName
+	p.Tags = []string{tag("host", hostname), tag("disk", device.Path), tag("type", strings.Replace(device.Type, ",", "_", -1)), tag("class", report.Class)}
+	p.Fields = []string{field("disk_status", info.Health)}
+	parseError := strings.TrimSpace(stdOut) != "" && !info.recognised()
+	report.Coverage = sectionCoverage(sections)
+	p.Fields = append(p.Fields, field("parse_error", parseError), field("unrecognised_lines", report.Coverage.count()))
+	if parseError {
+		log.Printf("%s: could not make sense of smartctl's output, %d lines unrecognised", device.Path, len(info.Unrecognised))
+		if *debug {
+			log.Printf("%s: unrecognised smartctl output: %q", device.Path, info.Unrecognised)
+		}
+	}
+	if bridgeType != "" {
+		p.Fields = append(p.Fields, field("bridge_type", bridgeType))
+	}
+
+	if matched := advisories.match(info.modelAndFirmware()); len(matched) > 0 {
+		ids := make([]string, 0, len(matched))
+		for _, advisory := range matched {
+			ids = append(ids, advisory.ID)
+			log.Printf("%s: firmware advisory %s (%s): %s", device.Path, advisory.ID, advisory.Severity, advisory.Description)
+		}
+		p.Fields = append(p.Fields,
+			field("firmware_advisory", strings.Join(ids, " ")),
+			field("firmware_advisory_severity", highestSeverity(matched)))
+	}
+
+	if kernelErrors != nil && name != "" {
+		counts := kernelErrors[name]
+		if counts == nil {
+			counts = &kernelErrorCounts{}
+		}
+		p.Fields = append(p.Fields,
+			field("kernel_io_errors", counts.IOErrors),
+			field("kernel_link_resets", counts.LinkResets),
+			field("kernel_timeouts", counts.Timeouts),
+			field("kernel_medium_errors", counts.MediumErrors))
+	}
+
+	if stats := ioStats[name]; stats != nil {
+		p.Fields = append(p.Fields, stats.fields()...)
+		var prev diskStats
+		if state.get("diskstats:"+stats.Name, &prev) {
+			if read, write, total, ok := stats.await(&prev); ok {
+				p.Fields = append(p.Fields,
+					field("read_await_ms", read),
+					field("write_await_ms", write),
+					field("await_ms", total))
+				// a disk without completed I/O has no await to compare
+				report.Await = total
+				report.HasAwait = stats.ReadsCompleted+stats.WritesCompleted > prev.ReadsCompleted+prev.WritesCompleted
+			}
+		}
+		state.set("diskstats:"+stats.Name, stats)
+	}
+
+	var devStats []*deviceStatistic
+	if *devStat && info.ATAVersion != "" {
+		devStats, _ = parseDeviceStatistics(sections["devstat"])
+		p.Fields = append(p.Fields, deviceStatisticsFields(devStats)...)
+	}
+
+	if info.SMARTSupport {
+		attrs, _ := parseAttributeList(sections["attributes"])
+		known := drives.lookup(info.DeviceModel, info.FirmwareVersion)
+		if *semanticNames {
+			canonicalize(attrs, known)
+		}
+		for _, attr := range attrs {
+			da, isKnown := known[attr.ID]
+			// TODO replace this by something more efficient
+			for _, id := range *attrIDs {
+				if id == strconv.Itoa(attr.ID) || isKnown && id == da.Name {
+					if *semanticNames && isKnown {
+						p.Fields = append(p.Fields, da.semanticString(attr))
+					} else {
+						p.Fields = append(p.Fields, attr.String(true, false))
 					}
+					break
 				}
 			}
 		}
+		if info.IsSSD {
+			p.Fields = append(p.Fields, ssdEnduranceFields(info, attrs, devStats, state, time.Now())...)
+		}
+		if info.SATAMaxSpeedGbps > 0 {
+			p.Fields = append(p.Fields, sataInterfaceFields(info, attrs, state)...)
+		}
+	}
+
+	if *sctTemp && info.ATAVersion != "" {
+		p.Fields = append(p.Fields, parseSCTTemperature(sections["scttemp"]).fields(*tempWarning, *tempCritical)...)
+	}
+
+	if *bgScan && info.isSAS() {
+		p.Fields = append(p.Fields, parseBackgroundScan(sections["background"]).fields()...)
+	}
+
+	if *sataPhy && info.SATAVersion != "" {
+		counters, _ := parseSATAPhyEventCounters(sections["sataphy"])
+		prev := map[string]int64{}
+		key := "sataphy:" + info.SerialNumber
+		state.get(key, &prev)
+		p.Fields = append(p.Fields, sataPhyFields(counters, prev)...)
+		if info.SerialNumber != "" {
+			current := make(map[string]int64, len(counters))
+			for _, counter := range counters {
+				current[counter.fieldName()] = counter.Value
+			}
+			state.set(key, current)
+		}
+	}
+
+	return report
+}
+
+// sataInterfaceFields reports the negotiated SATA link speed and whether the
+// link looks unhealthy, either because it negotiated below the drive's
+// maximum speed or because the drive counted new CRC errors since the
+// previous run.
+func sataInterfaceFields(info *smartCtlInfo, attrs []*smartAttribute, state *stateStore) []string {
+	fields := []string{field("sata_max_gbps", info.SATAMaxSpeedGbps)}
+	if info.SATACurrentSpeedGbps > 0 {
+		fields = append(fields, field("sata_current_gbps", info.SATACurrentSpeedGbps))
+	}
+	degraded := info.sataLinkDegraded()
+	fields = append(fields, field("sata_link_degraded", degraded))
+
+	crcErrorsIncreased := false
+	for _, attr := range attrs {
+		if attr.ID != 199 || info.SerialNumber == "" {
+			continue
+		}
+		key := "crc_errors:" + info.SerialNumber
+		var prev int64
+		if state.get(key, &prev) && attr.RawValue >= prev {
+			fields = append(fields, field("udma_crc_errors_delta", attr.RawValue-prev))
+			crcErrorsIncreased = attr.RawValue > prev
+		}
+		state.set(key, attr.RawValue)
+	}
+	return append(fields, field("interface_problem", degraded || crcErrorsIncreased))
+}
+
+func ssdEnduranceFields(info *smartCtlInfo, attrs []*smartAttribute, devStats []*deviceStatistic, state *stateStore, now time.Time) []string {
+	endurance := newSSDEndurance(info, attrs, drives)
+	endurance.fillFromDeviceStatistics(info, devStats)
+	fields := []string{}
+	if endurance.HasLifeUsed {
+		fields = append(fields, field("ssd_life_used_pct", endurance.LifeUsedPct))
+	}
+	if !endurance.HasWritten {
+		return fields
+	}
+	fields = append(fields, field("ssd_host_written_tb", endurance.BytesWritten/1e12))
+	if info.SerialNumber == "" {
+		return fields
+	}
+
+	// the first sample is kept as the baseline, so the projection uses the
+	// average write rate over the whole history instead of the last interval
+	key := "endurance:" + info.SerialNumber
+	var base enduranceSample
+	if !state.get(key, &base) || endurance.BytesWritten < base.BytesWritten {
+		base = enduranceSample{Time: now.Unix(), BytesWritten: endurance.BytesWritten}
+		state.set(key, base)
+	}
+
+	if ratedTB, ok := ratedTBWs.lookup(info.DeviceModel); ok {
+		fields = append(fields, field("ssd_rated_tbw", ratedTB))
+		if days, perDay, ok := daysToRatedTBW(ratedTB, endurance.BytesWritten, base, now); ok {
+			fields = append(fields,
+				field("ssd_write_rate_gb_per_day", perDay/1e9),
+				field("ssd_days_to_rated_tbw", days))
+		}
+	}
+	return fields
+}
+
+// scoreLatencyOutliers adds to every disk with a known average await how far
+// it deviates from its peer group, flagging the ones slow enough to be
+// suspicious.
+func scoreLatencyOutliers(reports []*diskReport, arrays map[string]string) {
+	groups := []string{}
+	awaits := []float64{}
+	scored := []*diskReport{}
+	for _, report := range reports {
+		group := peerGroup(*slowDiskGroup, report.Device, report.Info, arrays)
+		if !report.HasAwait || group == "" {
+			continue
+		}
+		groups = append(groups, group)
+		awaits = append(awaits, report.Await)
+		scored = append(scored, report)
+	}
+
+	for i, score := range outlierScores(groups, awaits) {
+		report := scored[i]
+		report.Point.Fields = append(report.Point.Fields,
+			field("latency_outlier_score", score),
+			field("slow_disk", score >= *slowDiskRatio && report.Await >= *slowDiskMinAwait))
+	}
+}
 
-		fmt.Println(strings.Join(values, ","))
+func collectKernelErrors(devices []deviceInfo, state *stateStore) map[string]*kernelErrorCounts {
+	out, err := readKernelLog(*kmsg)
+	if err != nil {
+		log.Println(err)
 	}
+	names := []string{}
+	for _, device := range devices {
+		if name := deviceKernelName(device); name != "" {
+			names = append(names, name)
+		}
+	}
+	lastSeq := int64(-1)
+	state.get("kmsg", &lastSeq)
+	counts, seq := countKernelErrors(out, lastSeq, ataPortDisks(names))
+	state.set("kmsg", seq)
+	return counts
 }
 
+// smartctl runs smartctl with the given arguments, if they are allowed.
 func smartctl(debug bool, args ...string) (string, string, error) {
-	cmd := exec.Command(*smartCtl, args...)
+	return privileged(debug, *smartCtl, args...)
+}
+
+// privilegedNames maps the paths of the privileged commands to their names
+// for checkPrivilegedArgs and privileged-exec.
+func privilegedNames() map[string]string {
+	return map[string]string{*smartCtl: "smartctl", *zpoolCmd: "zpool", *btrfsCmd: "btrfs", *storcliCmd: "storcli"}
+}
+
+// privileged runs a command needing root privileges, if its arguments are
+// allowed. In sudo privilege mode, it is run by the checker itself through
+// sudo, which checks them again as root.
+func privileged(debug bool, name string, args ...string) (string, string, error) {
+	command, ok := privilegedNames()[name]
+	if !ok {
+		return "", "", fmt.Errorf("%s: not a privileged command", name)
+	}
+	if err := checkPrivilegedArgs(command, args); err != nil {
+		return "", "", err
+	}
+	if *privilegeMode == "sudo" {
+		self, err := os.Executable()
+		if err != nil {
+			return "", "", err
+		}
+		return run(debug, *sudoCmd, append([]string{"-n", self, privilegedExecCommand, command}, args...)...)
+	}
+	return run(debug, name, args...)
+}
+
+// runner runs a command, returning its standard output and error.
+type runner func(name string, args ...string) (string, string, error)
+
+func debugRunner(name string, args ...string) (string, string, error) {
+	if name == *smartCtl {
+		return smartctl(*debug, args...)
+	}
+	return privileged(*debug, name, args...)
+}
+
+func run(debug bool, name string, args ...string) (string, string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Env = sanitizedEnv(os.Environ())
 	if debug {
-		log.Printf("Running `%s with args: %v", *smartCtl, args)
+		log.Printf("Running `%s with args: %v", name, args)
 	}
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -96,7 +769,7 @@ func smartctl(debug bool, args ...string) (string, string, error) {
 	err := cmd.Run()
 	outStr, errStr := string(stdout.Bytes()), string(stderr.Bytes())
 	if debug {
-		log.Printf("%s: stdout `%s`, stderr `%s`", *smartCtl, strings.TrimSpace(outStr), strings.TrimSpace(errStr))
+		log.Printf("%s: stdout `%s`, stderr `%s`", name, strings.TrimSpace(outStr), strings.TrimSpace(errStr))
 	}
 	return outStr, errStr, err
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// mdArrays maps the disks of each md software RAID array found under root,
+// usually /sys/block, to the array, from the partitions or disks listed as
+// its slaves.
+func mdArrays(root string) map[string]string {
+	arrays := map[string]string{}
+	blocks, err := ioutil.ReadDir(root)
+	if err != nil {
+		return arrays
+	}
+	for _, block := range blocks {
+		if !strings.HasPrefix(block.Name(), "md") {
+			continue
+		}
+		slaves, err := ioutil.ReadDir(filepath.Join(root, block.Name(), "slaves"))
+		if err != nil {
+			continue
+		}
+		for _, slave := range slaves {
+			arrays[parentDisk("/dev/"+slave.Name())] = "md:" + block.Name()
+		}
+	}
+	return arrays
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMDArrays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk-health-checker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, slave := range []string{"md0/slaves/sda1", "md0/slaves/sdb1", "md1/slaves/nvme0n1p2", "md1/slaves/nvme1n1p2", "sda/sda1"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, slave), 0755))
+	}
+
+	assert.Equal(t, map[string]string{
+		"/dev/sda":     "md:md0",
+		"/dev/sdb":     "md:md0",
+		"/dev/nvme0n1": "md:md1",
+		"/dev/nvme1n1": "md:md1",
+	}, mdArrays(dir))
+	assert.Empty(t, mdArrays(filepath.Join(dir, "missing")))
+}
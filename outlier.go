@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// peerGroup returns the name of the group of disks the given one is expected
+// to perform like, or an empty string if it has none.
+func peerGroup(mode string, device deviceInfo, info *smartCtlInfo, arrays map[string]string) string {
+	switch mode {
+	case "host":
+		return "host"
+	case "model":
+		if info.DeviceModel != "" {
+			return info.DeviceModel
+		}
+		return strings.TrimSpace(info.Vendor + " " + info.Product)
+	case "array":
+		// disks behind a RAID controller have no I/O statistics of their own,
+		// so only software arrays are known
+		return arrays[device.Path]
+	}
+	return ""
+}
+
+// outlierScores divides each await by the median await of the other disks in
+// the same group. Disks without peers, or whose peers did no I/O, are left
+// out of the result.
+func outlierScores(groups []string, awaits []float64) map[int]float64 {
+	members := map[string][]int{}
+	for i, group := range groups {
+		members[group] = append(members[group], i)
+	}
+
+	scores := map[int]float64{}
+	for i, group := range groups {
+		peers := []float64{}
+		for _, j := range members[group] {
+			if j != i {
+				peers = append(peers, awaits[j])
+			}
+		}
+		if len(peers) == 0 {
+			continue
+		}
+		if m := median(peers); m > 0 {
+			scores[i] = awaits[i] / m
+		}
+	}
+	return scores
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
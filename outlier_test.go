@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutlierScores(t *testing.T) {
+	groups := []string{"tank/raidz1-0", "tank/raidz1-0", "tank/raidz1-0", "tank/raidz1-0", "rpool/mirror-0", "lonely"}
+	awaits := []float64{4, 5, 25, 6, 0, 12}
+	scores := outlierScores(groups, awaits)
+	assert.Len(t, scores, 4)
+	assert.InDelta(t, 4.0/6, scores[0], 0.001)
+	assert.InDelta(t, 5.0/6, scores[1], 0.001)
+	assert.InDelta(t, 5.0, scores[2], 0.001)
+	assert.InDelta(t, 6.0/5, scores[3], 0.001)
+}
+
+func TestPeerGroup(t *testing.T) {
+	arrays := map[string]string{"/dev/sdb": "tank/raidz1-0"}
+	info := &smartCtlInfo{DeviceModel: "HGST HDN724040ALE640"}
+	megaraid := deviceInfo{Path: "/dev/bus/4", Type: "megaraid,14"}
+	sdb := deviceInfo{Path: "/dev/sdb", Type: "auto"}
+	sdc := deviceInfo{Path: "/dev/sdc", Type: "auto"}
+
+	assert.Equal(t, "", peerGroup("array", megaraid, info, arrays))
+	assert.Equal(t, "tank/raidz1-0", peerGroup("array", sdb, info, arrays))
+	assert.Equal(t, "", peerGroup("array", sdc, info, arrays))
+	assert.Equal(t, "HGST HDN724040ALE640", peerGroup("model", sdc, info, arrays))
+	assert.Equal(t, "LSI Logical Volume", peerGroup("model", sdc, &smartCtlInfo{Vendor: "LSI", Product: "Logical Volume"}, arrays))
+	assert.Equal(t, "host", peerGroup("host", sdc, info, arrays))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// deviceOverride sets the smartctl device type, and adds smartctl arguments
+// such as -F, -v or -T permissive, for the devices matching all of its path,
+// model regular expression and serial number that are set.
+type deviceOverride struct {
+	Path   string   `json:"path,omitempty"`
+	Model  string   `json:"model,omitempty"`
+	Serial string   `json:"serial,omitempty"`
+	Type   string   `json:"type,omitempty"`
+	Args   []string `json:"args,omitempty"`
+
+	modelRgx *regexp.Regexp
+}
+
+type overrideDB []*deviceOverride
+
+func (db overrideDB) compile() (err error) {
+	for _, override := range db {
+		if override.Type != "" && !validDeviceType(override.Type) {
+			return fmt.Errorf("override type %q: not a smartctl device type", override.Type)
+		}
+		if err := checkSmartctlOptions(override.Args); err != nil {
+			return err
+		}
+		if override.Model == "" {
+			continue
+		}
+		if override.modelRgx, err = regexp.Compile(override.Model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadOverrideDB reads per-device overrides from a JSON file.
+func loadOverrideDB(path string) (overrideDB, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db := overrideDB{}
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	return db, db.compile()
+}
+
+// needsIdentity tells whether matching a device requires asking smartctl
+// about its model or serial number.
+func (db overrideDB) needsIdentity() bool {
+	for _, override := range db {
+		if override.Model != "" || override.Serial != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (override *deviceOverride) matches(device deviceInfo, info *smartCtlInfo) bool {
+	if override.Path != "" && override.Path != device.Path {
+		return false
+	}
+	if override.modelRgx != nil {
+		model, _ := info.modelAndFirmware()
+		if !override.modelRgx.MatchString(model) {
+			return false
+		}
+	}
+	return override.Serial == "" || override.Serial == info.SerialNumber
+}
+
+// apply returns the device with the type and arguments of every matching
+// override, in order, so that later entries win over earlier ones.
+func (db overrideDB) apply(device deviceInfo, info *smartCtlInfo) deviceInfo {
+	for _, override := range db {
+		if !override.matches(device, info) {
+			continue
+		}
+		if override.Type != "" {
+			device.Type = override.Type
+		}
+		device.Args = append(append([]string{}, device.Args...), override.Args...)
+	}
+	return device
+}
+
+// deviceIdentity is what overrides match devices on besides their path, kept
+// in the state so that devices are not identified again on every run.
+type deviceIdentity struct {
+	Model  string
+	Serial string
+}
+
+func newDeviceIdentity(info *smartCtlInfo) deviceIdentity {
+	model, _ := info.modelAndFirmware()
+	return deviceIdentity{Model: model, Serial: info.SerialNumber}
+}
+
+func (identity deviceIdentity) info() *smartCtlInfo {
+	return &smartCtlInfo{DeviceModel: identity.Model, SerialNumber: identity.Serial}
+}
+
+// identityKey is the state key of a device's identity, by the scanned or
+// probed device rather than the type and arguments overrides give it.
+func identityKey(device deviceInfo) string {
+	if raw := strings.TrimSpace(device.Raw); raw != "" {
+		return "identity:" + raw
+	}
+	return "identity:" + device.Path + " -d " + device.Type
+}
+
+// rememberIdentity keeps the model and serial number smartctl reported for
+// the device, replacing the ones kept if another disk is there now.
+func rememberIdentity(state *stateStore, device deviceInfo, info *smartCtlInfo) {
+	identity := newDeviceIdentity(info)
+	var known deviceIdentity
+	if identity == (deviceIdentity{}) || state.get(identityKey(device), &known) && known == identity {
+		return
+	}
+	state.set(identityKey(device), identity)
+}
+
+// applyOverrides applies the overrides to the given devices. When overrides
+// match on model or serial number, devices whose identity is not in the state
+// yet are identified first.
+func applyOverrides(run runner, smartctlPath string, devices []deviceInfo, db overrideDB, state *stateStore) []deviceInfo {
+	overridden := make([]deviceInfo, 0, len(devices))
+	for _, device := range devices {
+		var identity deviceIdentity
+		if db.needsIdentity() && !state.get(identityKey(device), &identity) {
+			// the path alone may already select the type needed to identify it
+			stdOut, _, _ := run(smartctlPath, db.apply(device, &smartCtlInfo{}).args("-i")...)
+			info := parseSMARTCtlInfo(stdOut)
+			identity = newDeviceIdentity(info)
+			rememberIdentity(state, device, info)
+		}
+		overridden = append(overridden, db.apply(device, identity.info()))
+	}
+	return overridden
+}
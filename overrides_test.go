@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceArgs(t *testing.T) {
+	device := deviceInfo{Path: "/dev/sda", Type: "sat", Args: []string{"-F", "samsung3"}}
+	assert.Equal(t, []string{"-l", "scttemp", "-F", "samsung3", "/dev/sda", "-d", "sat"}, device.args("-l", "scttemp"))
+	assert.Equal(t, []string{"-A", "/dev/sdb", "-d", "auto"}, deviceInfo{Path: "/dev/sdb", Type: "auto"}.args("-A"))
+}
+
+func TestLoadOverrideDB(t *testing.T) {
+	file, err := ioutil.TempFile("", "overrides")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString(`[
+		{"model": "^SAMSUNG HD", "args": ["-v", "9,minutes"]},
+		{"path": "/dev/sdc", "type": "sat", "args": ["-T", "permissive"]},
+		{"serial": "WD-WMAZA1234567", "args": ["-F", "xerrorlba"]}
+	]`)
+	file.Close()
+
+	db, err := loadOverrideDB(file.Name())
+	assert.NoError(t, err)
+	assert.Len(t, db, 3)
+	assert.True(t, db.needsIdentity())
+
+	outputs := map[string]string{
+		"-i /dev/sda -d auto":              "Device Model:     SAMSUNG HD204UI\nSerial Number:    S2H7J1234567",
+		"-i -T permissive /dev/sdc -d sat": "Device Model:     WDC WD20EARX-00PASB0\nSerial Number:    WD-WMAZA1234567",
+		"-i /dev/bus/0 -d megaraid,1":      "Vendor:   SEAGATE\nProduct:  ST4000NM0023\nSerial number: Z1Z0ABCD",
+	}
+	identified := 0
+	run := func(name string, args ...string) (string, string, error) {
+		identified++
+		return outputs[strings.Join(args, " ")], "", nil
+	}
+	scanned := parseSMARTCtlScan(`/dev/sda -d scsi # /dev/sda, SCSI device
+/dev/sdc -d scsi # /dev/sdc, SCSI device
+/dev/bus/0 -d megaraid,1 # /dev/bus/0 [megaraid_disk_01], SCSI device`)
+	state := &stateStore{Entries: map[string]json.RawMessage{}}
+	devices := applyOverrides(run, "/usr/sbin/smartctl", scanned, db, state)
+	assert.Equal(t, 3, identified)
+
+	assert.Equal(t, "auto", devices[0].Type)
+	assert.Equal(t, []string{"-v", "9,minutes"}, devices[0].Args)
+	assert.Equal(t, "sat", devices[1].Type)
+	assert.Equal(t, []string{"-T", "permissive", "-F", "xerrorlba"}, devices[1].Args)
+	assert.Equal(t, "megaraid,1", devices[2].Type)
+	assert.Len(t, devices[2].Args, 0)
+
+	assert.Equal(t, []string{"-A", "-T", "permissive", "-F", "xerrorlba", "/dev/sdc", "-d", "sat"}, devices[1].args("-A"))
+
+	// identities are kept, so the next run does not identify the devices
+	assert.Equal(t, devices, applyOverrides(run, "/usr/sbin/smartctl", scanned, db, state))
+	assert.Equal(t, 3, identified)
+
+	// until another disk is found at a path
+	rememberIdentity(state, devices[1], &smartCtlInfo{DeviceModel: "WDC WD40EFRX-68N32N0", SerialNumber: "WD-WCC7K1234567"})
+	devices = applyOverrides(run, "/usr/sbin/smartctl", scanned, db, state)
+	assert.Equal(t, 3, identified)
+	assert.Equal(t, []string{"-T", "permissive"}, devices[1].Args)
+
+	// members resolved from a RAID volume are identified like scanned devices
+	outputs["-i /dev/sda -d megaraid,1"] = outputs["-i /dev/bus/0 -d megaraid,1"]
+	members := []deviceInfo{{Raw: "/dev/sda -d megaraid,1", Path: "/dev/sda", Type: "megaraid,1"}}
+	byModel := overrideDB{{Model: "^ST4000", Args: []string{"-T", "permissive"}}}
+	assert.NoError(t, byModel.compile())
+	members = applyOverrides(run, "/usr/sbin/smartctl", members, byModel, state)
+	assert.Equal(t, 4, identified)
+	assert.Equal(t, []string{"-T", "permissive"}, members[0].Args)
+}
+
+func TestOverridesWithoutIdentity(t *testing.T) {
+	db := overrideDB{{Path: "/dev/sdb", Type: "usbjmicron"}}
+	assert.NoError(t, db.compile())
+	assert.False(t, db.needsIdentity())
+	run := func(name string, args ...string) (string, string, error) {
+		t.Fatal("devices are identified without model or serial overrides")
+		return "", "", nil
+	}
+	devices := applyOverrides(run, "/usr/sbin/smartctl", []deviceInfo{{Path: "/dev/sda", Type: "auto"}, {Path: "/dev/sdb", Type: "auto"}}, db, &stateStore{Entries: map[string]json.RawMessage{}})
+	assert.Equal(t, "auto", devices[0].Type)
+	assert.Equal(t, "usbjmicron", devices[1].Type)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var (
+	devicePathRgx = regexp.MustCompile(`^/dev(/[A-Za-z0-9_.:+@-]+)+$`)
+	// deviceTypeRgx is the grammar of smartctl's -d device types
+	deviceTypeRgx = regexp.MustCompile(`^(` + strings.Join([]string{
+		`ata`, `scsi`, `auto`, `test`, `marvell`, `usbprolific`, `usbsunplus`, `sntasmedia`, `sntrealtek`,
+		`sntjmicron(,\d+)?`,
+		`nvme(,(0x[0-9a-fA-F]+|\d+))?`,
+		`sat(,auto)?(,(12|16))?`,
+		`usbcypress(,0x[0-9a-fA-F]{2})?`,
+		`usbjmicron(,p)?(,x)?(,[01])?`,
+		`usbasm1352r(,[01])?`,
+		`(sat(,auto)?(,(12|16))?\+)?(megaraid|cciss),\d+`,
+		`aacraid,\d+,\d+,\d+`,
+		`areca,\d+(/\d+)?`,
+		`3ware,\d+`,
+		`hpt,\d+/\d+(/\d+)?`,
+		`intelliprop,\d+(\+[a-z0-9,]+)?`,
+		`jmb39x(-q|-q2)?,\d+(,s\d+)?(,force)?(\+[a-z0-9,]+)?`,
+	}, "|") + `)$`)
+
+	// smartctlFlags are the smartctl options taking no value the checker may use
+	smartctlFlags = map[string]bool{"--scan": true, "-i": true, "-H": true, "-A": true, "-c": true, "-a": true, "-x": true}
+	// smartctlOptions are the smartctl options taking a value the checker may
+	// use, with the values allowed
+	smartctlOptions = map[string]*regexp.Regexp{
+		"-d": deviceTypeRgx,
+		"-l": regexp.MustCompile(`^(devstat|scttemp|background|sataphy|selftest|error|xerror|scterc)$`),
+		"-t": regexp.MustCompile(`^(short|long)$`),
+		"-F": regexp.MustCompile(`^[a-z0-9_]+$`),
+		"-v": regexp.MustCompile(`^\d+,[A-Za-z0-9_,:]+$`),
+		"-T": regexp.MustCompile(`^(normal|conservative|permissive|verypermissive)$`),
+		"-n": regexp.MustCompile(`^(never|sleep|standby|idle)(,\d+)?$`),
+	}
+)
+
+// validDevicePath tells whether the path names a node under /dev, without
+// any component that could be taken for something else.
+func validDevicePath(path string) bool {
+	return devicePathRgx.MatchString(path) && filepath.Clean(path) == path
+}
+
+// validDeviceType tells whether the type follows smartctl's -d grammar.
+func validDeviceType(deviceType string) bool {
+	return deviceTypeRgx.MatchString(deviceType)
+}
+
+// smartctlDevices returns the positional arguments, i.e. the devices, and an
+// error unless all other arguments are allowed smartctl options and values.
+func smartctlDevices(args []string) ([]string, error) {
+	devices := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if smartctlFlags[arg] {
+			continue
+		}
+		if values, ok := smartctlOptions[arg]; ok {
+			if i+1 >= len(args) || !values.MatchString(args[i+1]) {
+				return nil, fmt.Errorf("smartctl option %s: value not allowed", arg)
+			}
+			i++
+			continue
+		}
+		if !validDevicePath(arg) {
+			return nil, fmt.Errorf("smartctl argument %q: neither an allowed option nor a device", arg)
+		}
+		devices = append(devices, arg)
+	}
+	return devices, nil
+}
+
+// checkSmartctlArgs returns an error unless the arguments only use the
+// allowed smartctl options and values, and name at most one device.
+func checkSmartctlArgs(args []string) error {
+	devices, err := smartctlDevices(args)
+	if err == nil && len(devices) > 1 {
+		err = fmt.Errorf("smartctl arguments %v: only one device allowed", devices)
+	}
+	return err
+}
+
+// checkSmartctlOptions returns an error unless the arguments are only allowed
+// smartctl options and values.
+func checkSmartctlOptions(args []string) error {
+	devices, err := smartctlDevices(args)
+	if err == nil && len(devices) > 0 {
+		err = fmt.Errorf("smartctl arguments %v: no device allowed", devices)
+	}
+	return err
+}
+
+// privilegedExecCommand is the command sudo runs the checker itself with, as
+// root, to run one of the privileged commands below once its arguments pass
+// checkPrivilegedArgs.
+const privilegedExecCommand = "privileged-exec"
+
+// privilegedPaths are where privileged-exec looks for each command it runs.
+// They are fixed, as nothing given by the unprivileged checker is trusted.
+var privilegedPaths = map[string][]string{
+	"smartctl": {"/usr/sbin/smartctl", "/usr/bin/smartctl", "/sbin/smartctl"},
+	"zpool":    {"/sbin/zpool", "/usr/sbin/zpool"},
+	"btrfs":    {"/bin/btrfs", "/usr/bin/btrfs", "/sbin/btrfs", "/usr/sbin/btrfs"},
+	"storcli":  {"/opt/MegaRAID/storcli/storcli64", "/opt/MegaRAID/perccli/perccli64"},
+}
+
+// storcliArgs are the storcli commands collectMegaRAID runs.
+var storcliArgs = [][]string{
+	{"/call", "show", "all", "J"},
+	{"/call", "show", "patrolread", "J"},
+	{"/call/eall/sall", "show", "all", "J"},
+}
+
+// checkPrivilegedArgs returns an error unless the arguments are ones the
+// checker runs the named privileged command with.
+func checkPrivilegedArgs(name string, args []string) error {
+	switch name {
+	case "smartctl":
+		return checkSmartctlArgs(args)
+	case "zpool":
+		if equalArgs(args, []string{"status", "-p"}) {
+			return nil
+		}
+	case "btrfs":
+		// a mount point, which cannot be taken for an option such as -z
+		if len(args) == 3 && args[0] == "device" && args[1] == "stats" && filepath.IsAbs(args[2]) && filepath.Clean(args[2]) == args[2] {
+			return nil
+		}
+	case "storcli":
+		for _, allowed := range storcliArgs {
+			if equalArgs(args, allowed) {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("%s: not a privileged command", name)
+	}
+	return fmt.Errorf("%s arguments %q: not allowed", name, args)
+}
+
+func equalArgs(args, expected []string) bool {
+	if len(args) != len(expected) {
+		return false
+	}
+	for i := range args {
+		if args[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// privilegedExec runs, in place of the checker, the privileged command named
+// by the first argument with the others, if they are allowed.
+func privilegedExec(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s: no command given", privilegedExecCommand)
+	}
+	name, args := args[0], args[1:]
+	if err := checkPrivilegedArgs(name, args); err != nil {
+		return err
+	}
+	if name == "smartctl" {
+		devices, _ := smartctlDevices(args)
+		if err := checkDeviceNodes(devices); err != nil {
+			return err
+		}
+	}
+	for _, path := range privilegedPaths[name] {
+		if _, err := os.Stat(path); err == nil {
+			return syscall.Exec(path, append([]string{path}, args...), sanitizedEnv(os.Environ()))
+		}
+	}
+	return fmt.Errorf("%s: not found in %v", name, privilegedPaths[name])
+}
+
+// checkDeviceNodes returns an error unless every path is, or links to, a
+// block or character device, rather than e.g. a file under /dev/shm.
+func checkDeviceNodes(paths []string) error {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeDevice == 0 {
+			return fmt.Errorf("%s: not a device", path)
+		}
+	}
+	return nil
+}
+
+// sanitizedEnv returns the environment commands are run with: a fixed PATH,
+// the C locale our parsers expect, and none of the inherited variables that
+// could change what they do or print.
+func sanitizedEnv(environ []string) []string {
+	env := []string{"PATH=/usr/sbin:/usr/bin:/sbin:/bin", "LC_ALL=C"}
+	for _, v := range environ {
+		if strings.HasPrefix(v, "TZ=") {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// suspendPrivileges makes a setuid process run as the user who ran it until
+// resumePrivileges, so that its arguments are not parsed as root. It returns
+// the effective user it gave up, or -1 if it had none to give up.
+func suspendPrivileges() (int, error) {
+	uid, euid := os.Getuid(), os.Geteuid()
+	if euid == uid {
+		return -1, nil
+	}
+	// the saved user is left alone, to resume with
+	return euid, syscall.Setresuid(-1, uid, -1)
+}
+
+// resumePrivileges gets back the effective user given up by
+// suspendPrivileges.
+func resumePrivileges(euid int) error {
+	if euid < 0 {
+		return nil
+	}
+	return syscall.Setresuid(-1, euid, -1)
+}
+
+// dropPrivileges gives up root for the rest of the run: a setuid binary goes
+// back to the user who ran it, and a process run by root becomes the given
+// user.
+func dropPrivileges(username string) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		u, err := user.Lookup(username)
+		if err != nil {
+			return err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return err
+		}
+	}
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return err
+	}
+	if err := syscall.Setresgid(gid, gid, gid); err != nil {
+		return err
+	}
+	if err := syscall.Setresuid(uid, uid, uid); err != nil {
+		return err
+	}
+	if os.Geteuid() == 0 {
+		return fmt.Errorf("still running as root after dropping privileges to %s", username)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSmartctlArgsAllowed(t *testing.T) {
+	sda := deviceInfo{Path: "/dev/sda", Type: "auto"}
+	allowed := [][]string{
+		{"--scan"},
+		sda.args("-i", "-H", "-A", "-l", "devstat", "-l", "scttemp", "-l", "background", "-l", "sataphy"),
+		sda.args("-i", "-c"),
+		sda.args("-t", "long"),
+		{"-i", "/dev/sg0", "-d", "cciss,3"},
+		{"-i", "/dev/sda", "-d", "aacraid,0,0,1"},
+		deviceInfo{Path: "/dev/bus/0", Type: "sat+megaraid,14"}.args("-i", "-H"),
+		deviceInfo{Path: "/dev/sdc", Type: "usbjmicron", Args: []string{"-F", "samsung3", "-v", "9,minutes", "-v", "1,raw48:54", "-T", "permissive", "-n", "standby,15"}}.args("-A"),
+	}
+	for _, args := range allowed {
+		assert.NoError(t, checkSmartctlArgs(args), "%v", args)
+	}
+}
+
+func TestCheckSmartctlArgsRejected(t *testing.T) {
+	rejected := [][]string{
+		{"-s", "off", "/dev/sda"},
+		{"--smart=off", "/dev/sda"},
+		{"-t", "offline", "/dev/sda"},
+		{"-X", "/dev/sda"},
+		{"-l", "gplog,0x04", "/dev/sda"},
+		{"-d", "auto;rm", "/dev/sda"},
+		{"-i", "/dev/sda", "-d"},
+		{"-F", "$(reboot)", "/dev/sda"},
+		{"-i", "/dev/sda", "/dev/sdb"},
+		{"-B", "/tmp/drivedb.h", "/dev/sda"},
+	}
+	for _, args := range rejected {
+		assert.Error(t, checkSmartctlArgs(args), "%v", args)
+	}
+}
+
+func TestValidDevicePath(t *testing.T) {
+	for path, valid := range map[string]bool{
+		"/dev/sda":        true,
+		"/dev/bus/4":      true,
+		"/dev/sg0":        true,
+		"/dev/nvme0n1":    true,
+		"/dev/cciss/c0d0": true,
+		"/dev/disk/by-id/ata-HGST_HDN724040ALE640_PK2338P4H4XPXC": true,
+		"/dev/disk/by-path/pci-0000:00:1f.2-ata-1":                true,
+		"":                    false,
+		"sda":                 false,
+		"/dev":                false,
+		"/dev/":               false,
+		"/dev//sda":           false,
+		"/dev/./sda":          false,
+		"/dev/../etc/shadow":  false,
+		"/dev/..":             false,
+		"/etc/passwd":         false,
+		"-i":                  false,
+		"--scan":              false,
+		"/dev/sda; rm -rf /":  false,
+		"/dev/sda\n":          false,
+		"/dev/sd a":           false,
+		"/dev/$(reboot)":      false,
+		"/dev/sda`reboot`":    false,
+		"/dev/sda|tee /etc/x": false,
+	} {
+		assert.Equal(t, valid, validDevicePath(path), "%q", path)
+	}
+}
+
+func TestValidDeviceType(t *testing.T) {
+	for deviceType, valid := range map[string]bool{
+		"auto":               true,
+		"ata":                true,
+		"scsi":               true,
+		"nvme":               true,
+		"nvme,0xffffffff":    true,
+		"sat":                true,
+		"sat,12":             true,
+		"sat,auto,16":        true,
+		"usbjmicron":         true,
+		"usbjmicron,p,x,1":   true,
+		"usbcypress,0x24":    true,
+		"megaraid,14":        true,
+		"sat+megaraid,15":    true,
+		"cciss,3":            true,
+		"aacraid,0,0,1":      true,
+		"areca,2/1":          true,
+		"3ware,0":            true,
+		"hpt,1/1/2":          true,
+		"jmb39x,0,force+sat": true,
+		"":                   false,
+		"megaraid":           false,
+		"megaraid,":          false,
+		"megaraid,-1":        false,
+		"aacraid,0,0":        false,
+		"sat;reboot":         false,
+		"auto -s off":        false,
+		"-s":                 false,
+		"sat\n":              false,
+		"SAT":                false,
+		"usbjmicron,y":       false,
+	} {
+		assert.Equal(t, valid, validDeviceType(deviceType), "%q", deviceType)
+	}
+}
+
+func TestCheckSmartctlArgsHostile(t *testing.T) {
+	hostile := [][]string{
+		{"-i", "-H", "-s off", "/dev/sda"},
+		{"-i", "/dev/sda", "-d", "sat+megaraid,1;reboot"},
+		{"-i", "/dev/../etc/shadow", "-d", "auto"},
+		{"-i", "--", "-s", "-d", "auto"},
+		{"-i", "/dev/sda", "-d", "auto", "--smart=off"},
+		{"-A", "-v", "9,minutes;reboot", "/dev/sda", "-d", "auto"},
+		{"-A", "-F", "samsung3 -s off", "/dev/sda", "-d", "auto"},
+		{"-i", "sda", "-d", "auto"},
+		{"-i", "/dev/sda", "-d", "auto", "/dev/sdb"},
+	}
+	for _, args := range hostile {
+		assert.Error(t, checkSmartctlArgs(args), "%q", args)
+	}
+	assert.NoError(t, checkSmartctlOptions([]string{"-F", "samsung3", "-T", "permissive"}))
+	assert.Error(t, checkSmartctlOptions([]string{"-F", "samsung3", "/dev/sda"}))
+}
+
+func TestCheckPrivilegedArgs(t *testing.T) {
+	allowed := []struct {
+		Name string
+		Args []string
+	}{
+		{"smartctl", []string{"-i", "-H", "-A", "/dev/sda", "-d", "sat"}},
+		{"zpool", []string{"status", "-p"}},
+		{"btrfs", []string{"device", "stats", "/srv/data"}},
+		{"storcli", []string{"/call", "show", "all", "J"}},
+		{"storcli", []string{"/call", "show", "patrolread", "J"}},
+		{"storcli", []string{"/call/eall/sall", "show", "all", "J"}},
+	}
+	for _, command := range allowed {
+		assert.NoError(t, checkPrivilegedArgs(command.Name, command.Args), "%s %q", command.Name, command.Args)
+	}
+
+	hostile := []struct {
+		Name string
+		Args []string
+	}{
+		{"smartctl", []string{"-s", "off", "/dev/sda"}},
+		{"zpool", []string{"clear", "tank"}},
+		{"zpool", []string{"status", "-p", "tank"}},
+		{"btrfs", []string{"device", "stats", "-z", "/srv/data"}},
+		{"btrfs", []string{"device", "stats", "/srv/data", "-z"}},
+		{"btrfs", []string{"device", "stats", "srv"}},
+		{"btrfs", []string{"device", "stats", "/srv/../etc"}},
+		{"storcli", []string{"/c0", "set", "patrolread=off"}},
+		{"storcli", []string{"/call", "show", "all"}},
+		{"sh", []string{"-c", "reboot"}},
+	}
+	for _, command := range hostile {
+		assert.Error(t, checkPrivilegedArgs(command.Name, command.Args), "%s %q", command.Name, command.Args)
+	}
+
+	assert.Error(t, privilegedExec(nil))
+	assert.Error(t, privilegedExec([]string{"sh", "-c", "reboot"}))
+	assert.Error(t, privilegedExec([]string{"btrfs", "device", "stats", "-z", "/srv/data"}))
+}
+
+func TestCheckDeviceNodes(t *testing.T) {
+	file, err := ioutil.TempFile("", "disk-health-checker")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	assert.NoError(t, checkDeviceNodes(nil))
+	assert.NoError(t, checkDeviceNodes([]string{"/dev/null"}))
+	assert.Error(t, checkDeviceNodes([]string{"/dev/null", file.Name()}))
+	assert.Error(t, checkDeviceNodes([]string{"/dev/disk-health-checker-missing"}))
+	assert.Error(t, checkDeviceNodes([]string{"/dev"}))
+
+	assert.Error(t, privilegedExec([]string{"smartctl", "-i", "/dev/disk-health-checker-missing", "-d", "sat"}))
+}
+
+func TestParseHostileSMARTCtlScan(t *testing.T) {
+	devices := parseSMARTCtlScan(`/dev/sda -d scsi # /dev/sda, SCSI device
+/dev/sdb;reboot -d scsi # /dev/sdb, SCSI device
+/dev/bus/4 -d megaraid,14;reboot # /dev/bus/4 [megaraid_disk_14], SCSI device
+/dev/../etc/shadow -d ata # hostile
+-s -d ata # hostile
+/dev/bus/4 -d megaraid,15 # /dev/bus/4 [megaraid_disk_15], SCSI device`)
+	assert.Equal(t, []deviceInfo{
+		{Raw: "/dev/sda -d scsi ", Path: "/dev/sda", Type: "auto"},
+		{Raw: "/dev/bus/4 -d megaraid,15 ", Path: "/dev/bus/4", Type: "megaraid,15"},
+	}, devices)
+}
+
+func TestHostileOverrides(t *testing.T) {
+	for _, db := range []overrideDB{
+		{{Path: "/dev/sda", Type: "sat;reboot"}},
+		{{Path: "/dev/sda", Args: []string{"-s", "off"}}},
+		{{Path: "/dev/sda", Args: []string{"/dev/sdb"}}},
+		{{Path: "/dev/sda", Args: []string{"-F"}}},
+	} {
+		assert.Error(t, db.compile(), "%+v", db[0])
+	}
+}
+
+func TestSanitizedEnv(t *testing.T) {
+	env := sanitizedEnv([]string{"PATH=/tmp/evil:/usr/bin", "LANG=de_DE.UTF-8", "LC_ALL=de_DE.UTF-8", "LD_PRELOAD=/tmp/evil.so", "TZ=Europe/Berlin", "HOME=/root"})
+	assert.Equal(t, []string{"PATH=/usr/sbin:/usr/bin:/sbin:/bin", "LC_ALL=C", "TZ=Europe/Berlin"}, env)
+}
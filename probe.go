@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// probeControllerDisks finds the disks behind a RAID controller that smartctl
+// --scan does not enumerate, by asking smartctl about each index of the given
+// device type in turn, e.g. cciss,0 to cciss,max-1.
+func probeControllerDisks(run runner, smartctlPath, path, typePrefix string, max int) []deviceInfo {
+	devices := []deviceInfo{}
+	for i := 0; i < max; i++ {
+		deviceType := fmt.Sprintf("%s,%d", typePrefix, i)
+		stdOut, _, _ := run(smartctlPath, "-i", path, "-d", deviceType)
+		// smartctl exits non-zero for disks with SMART errors too, so only
+		// trust whether it could identify one
+		if info := parseSMARTCtlInfo(stdOut); info.DeviceModel != "" || info.Product != "" {
+			devices = append(devices, deviceInfo{Raw: path + " -d " + deviceType, Path: path, Type: deviceType})
+		}
+	}
+	return devices
+}
+
+// probeDevices adds to the scanned devices the members of the given HP Smart
+// Array controllers, addressed by device path, and of the given Adaptec
+// controllers, addressed by device path and "host,lun".
+func probeDevices(run runner, smartctlPath string, scanned []deviceInfo, cciss []string, aacraid map[string]string, max int) []deviceInfo {
+	devices := append([]deviceInfo{}, scanned...)
+	seen := map[string]bool{}
+	for _, device := range scanned {
+		seen[device.Path+" "+device.Type] = true
+	}
+	add := func(probed []deviceInfo) {
+		for _, device := range probed {
+			if !seen[device.Path+" "+device.Type] {
+				seen[device.Path+" "+device.Type] = true
+				devices = append(devices, device)
+			}
+		}
+	}
+
+	for _, path := range cciss {
+		add(probeControllerDisks(run, smartctlPath, path, "cciss", max))
+	}
+	paths := make([]string, 0, len(aacraid))
+	for path := range aacraid {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		add(probeControllerDisks(run, smartctlPath, path, "aacraid,"+normalizeHostLUN(aacraid[path]), max))
+	}
+	return devices
+}
+
+// normalizeHostLUN removes the spaces of an Adaptec host and LUN, given as
+// H,L or "H, L".
+func normalizeHostLUN(hostLUN string) string {
+	return strings.Replace(hostLUN, " ", "", -1)
+}
+
+// validHostLUN tells whether the Adaptec host and LUN make a valid aacraid
+// device type once normalized.
+func validHostLUN(hostLUN string) bool {
+	return validDeviceType("aacraid," + normalizeHostLUN(hostLUN) + ",0")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ccissInfoOutput = `
+smartctl 6.5 2016-01-24 r4214 [x86_64-linux-4.4.0-31-generic] (local build)
+Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
+
+=== START OF INFORMATION SECTION ===
+Vendor:               HP
+Product:              EG0300FBDBR
+Revision:             HPD9
+User Capacity:        300,000,000,000 bytes [300 GB]
+Logical block size:   512 bytes
+Rotation Rate:        10000 rpm
+Serial number:        ECA1PC50W8UT1234
+Device type:          disk
+Transport protocol:   SAS (SPL-3)
+Local Time is:        Tue Jun 27 11:37:47 2017 CEST
+SMART support is:     Available - device has SMART capability.
+SMART support is:     Enabled
+`
+
+var ccissMissingOutput = `
+smartctl 6.5 2016-01-24 r4214 [x86_64-linux-4.4.0-31-generic] (local build)
+Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
+
+Smartctl open device: /dev/sg0 [cciss_disk_02] [SCSI/SAT] failed: No such device
+`
+
+var aacraidInfoOutput = `
+smartctl 6.5 2016-01-24 r4214 [x86_64-linux-4.4.0-31-generic] (local build)
+Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
+
+=== START OF INFORMATION SECTION ===
+Model Family:     Western Digital RE4
+Device Model:     WDC WD2003FYYS-02W0B0
+Serial Number:    WD-WMAY01234567
+LU WWN Device Id: 5 0014ee 25b4a1234
+Firmware Version: 01.01D01
+User Capacity:    2,000,398,934,016 bytes [2.00 TB]
+Sector Size:      512 bytes logical/physical
+Rotation Rate:    7200 rpm
+Device is:        In smartctl database [for details use: -P show]
+ATA Version is:   ATA8-ACS (minor revision not indicated)
+SATA Version is:  SATA 2.6, 3.0 Gb/s
+Local Time is:    Tue Jun 27 11:37:47 2017 CEST
+SMART support is: Available - device has SMART capability.
+SMART support is: Enabled
+`
+
+var aacraidMissingOutput = `
+smartctl 6.5 2016-01-24 r4214 [x86_64-linux-4.4.0-31-generic] (local build)
+Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
+
+Smartctl open device: /dev/sda [aacraid_disk_00_00_2] failed: INQUIRY failed
+`
+
+func fakeProbeRunner(t *testing.T, outputs map[string]string) runner {
+	return func(name string, args ...string) (string, string, error) {
+		assert.Equal(t, "/usr/sbin/smartctl", name)
+		if out, ok := outputs[strings.Join(args, " ")]; ok {
+			return out, "", nil
+		}
+		if strings.Contains(args[len(args)-1], "aacraid") {
+			return aacraidMissingOutput, "", nil
+		}
+		return ccissMissingOutput, "", nil
+	}
+}
+
+func TestProbeCCISSDisks(t *testing.T) {
+	run := fakeProbeRunner(t, map[string]string{
+		"-i /dev/sg0 -d cciss,0": ccissInfoOutput,
+		"-i /dev/sg0 -d cciss,1": ccissInfoOutput,
+		"-i /dev/sg0 -d cciss,5": ccissInfoOutput,
+	})
+	devices := probeControllerDisks(run, "/usr/sbin/smartctl", "/dev/sg0", "cciss", 8)
+	assert.Equal(t, []deviceInfo{
+		{Raw: "/dev/sg0 -d cciss,0", Path: "/dev/sg0", Type: "cciss,0"},
+		{Raw: "/dev/sg0 -d cciss,1", Path: "/dev/sg0", Type: "cciss,1"},
+		{Raw: "/dev/sg0 -d cciss,5", Path: "/dev/sg0", Type: "cciss,5"},
+	}, devices)
+}
+
+func TestProbeDevices(t *testing.T) {
+	run := fakeProbeRunner(t, map[string]string{
+		"-i /dev/sg0 -d cciss,0":       ccissInfoOutput,
+		"-i /dev/sda -d aacraid,0,0,0": aacraidInfoOutput,
+		"-i /dev/sda -d aacraid,0,0,1": aacraidInfoOutput,
+	})
+	scanned := parseSMARTCtlScan(`/dev/sda -d scsi # /dev/sda, SCSI device
+/dev/sg0 -d cciss,0 # /dev/sg0 [cciss_disk_00], CCISS device`)
+	devices := probeDevices(run, "/usr/sbin/smartctl", scanned, []string{"/dev/sg0"}, map[string]string{"/dev/sda": "0, 0"}, 4)
+	assert.Equal(t, []deviceInfo{
+		{Raw: "/dev/sda -d scsi ", Path: "/dev/sda", Type: "auto"},
+		{Raw: "/dev/sg0 -d cciss,0 ", Path: "/dev/sg0", Type: "cciss,0"},
+		{Raw: "/dev/sda -d aacraid,0,0,0", Path: "/dev/sda", Type: "aacraid,0,0,0"},
+		{Raw: "/dev/sda -d aacraid,0,0,1", Path: "/dev/sda", Type: "aacraid,0,0,1"},
+	}, devices)
+
+	for _, hostLUN := range []string{"0,0", "0, 0", " 1 , 2 "} {
+		assert.True(t, validHostLUN(hostLUN), hostLUN)
+	}
+	for _, hostLUN := range []string{"0", "0,0,0", "0;reboot,0", "a,b"} {
+		assert.False(t, validHostLUN(hostLUN), hostLUN)
+	}
+
+	assert.Equal(t, "ECA1PC50W8UT1234", parseSMARTCtlInfo(ccissInfoOutput).SerialNumber)
+	assert.Equal(t, "HP EG0300FBDBR", peerGroup("model", devices[1], parseSMARTCtlInfo(ccissInfoOutput), nil))
+}
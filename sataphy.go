@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type sataPhyCounter struct {
+	ID          int
+	Size        int
+	Value       int64
+	Saturated   bool
+	Description string
+}
+
+// sataPhyCounterNames are short field names for the counters defined by the
+// SATA specification, other counters are named after their ID.
+var sataPhyCounterNames = map[int]string{
+	0x0001: "icrc_errors",
+	0x0002: "r_err_data_fis",
+	0x0003: "r_err_d2h_data_fis",
+	0x0004: "r_err_h2d_data_fis",
+	0x0005: "r_err_non_data_fis",
+	0x0006: "r_err_d2h_non_data_fis",
+	0x0007: "r_err_h2d_non_data_fis",
+	0x0008: "d2h_non_data_fis_retries",
+	0x0009: "phyrdy_to_phynrdy",
+	0x000a: "comreset_count",
+	0x000b: "h2d_fis_crc_errors",
+	0x000d: "h2d_fis_non_crc_errors",
+	0x000f: "r_err_h2d_data_fis_crc",
+	0x0010: "r_err_h2d_data_fis_non_crc",
+	0x0012: "r_err_h2d_non_data_fis_crc",
+	0x0013: "r_err_h2d_non_data_fis_non_crc",
+}
+
+var (
+	sataPhyCounterRgx = regexp.MustCompile(`^0x([0-9a-fA-F]{4})\s+(\d+)\s+(\d+)(\+?)\s+(.*)$`)
+	sataPhyHeaderRgx  = regexp.MustCompile(`^(SATA Phy Event Counters|ID\s+Size\s+Value\s+Description)`)
+)
+
+// parseSATAPhyEventCounters also returns the lines that are neither counters
+// nor headers.
+func parseSATAPhyEventCounters(out string) ([]*sataPhyCounter, []string) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	counters := []*sataPhyCounter{}
+	unrecognised := []string{}
+	for _, line := range lines {
+		m := sataPhyCounterRgx.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			if !smartctlBoilerplate(line) && !sataPhyHeaderRgx.MatchString(strings.TrimSpace(line)) {
+				unrecognised = append(unrecognised, line)
+			}
+			continue
+		}
+		id, _ := strconv.ParseInt(m[1], 16, 32)
+		counter := &sataPhyCounter{ID: int(id), Saturated: m[4] == "+", Description: strings.TrimSpace(m[5])}
+		counter.Size, _ = strconv.Atoi(m[2])
+		counter.Value, _ = strconv.ParseInt(m[3], 10, 64)
+		counters = append(counters, counter)
+	}
+	return counters, unrecognised
+}
+
+func (c *sataPhyCounter) fieldName() string {
+	if name, ok := sataPhyCounterNames[c.ID]; ok {
+		return "sataphy_" + name
+	}
+	return fmt.Sprintf("sataphy_0x%04x", c.ID)
+}
+
+// sataPhyFields reports every counter, and how much it grew since prev when
+// it did not go backwards, as the counters are reset on power cycles.
+func sataPhyFields(counters []*sataPhyCounter, prev map[string]int64) []string {
+	fields := []string{}
+	for _, counter := range counters {
+		name := counter.fieldName()
+		fields = append(fields, field(name, counter.Value))
+		if before, ok := prev[name]; ok && counter.Value >= before {
+			fields = append(fields, field(name+"_delta", counter.Value-before))
+		}
+	}
+	return fields
+}
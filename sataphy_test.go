@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sataPhyOutput = `
+smartctl 6.6 2016-05-31 r4324 [x86_64-linux-4.4.35-2-pve] (local build)
+Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
+
+SATA Phy Event Counters (GP Log 0x11)
+ID      Size     Value  Description
+0x0001  2            0  Command failed due to ICRC error
+0x0002  2            0  R_ERR response for data FIS
+0x0003  2            0  R_ERR response for device-to-host data FIS
+0x0004  2            0  R_ERR response for host-to-device data FIS
+0x0005  2            0  R_ERR response for non-data FIS
+0x0006  2            0  R_ERR response for device-to-host non-data FIS
+0x0007  2            0  R_ERR response for host-to-device non-data FIS
+0x0008  2            0  Device-to-host non-data FIS retries
+0x0009  2           14  Transition from drive PhyRdy to drive PhyNRdy
+0x000a  2            9  Device-to-host register FISes sent due to a COMRESET
+0x000b  2            3  CRC errors within host-to-device FIS
+0x000d  2            0  Non-CRC errors within host-to-device FIS
+0x000f  2            0  R_ERR response for host-to-device data FIS, CRC
+0x0010  2            0  R_ERR response for host-to-device data FIS, non-CRC
+0x0012  2            0  R_ERR response for host-to-device non-data FIS, CRC
+0x0013  2            0  R_ERR response for host-to-device non-data FIS, non-CRC
+0x8000  4        65535+ Vendor specific
+
+`
+
+func TestParseSATAPhyEventCounters(t *testing.T) {
+	counters, _ := parseSATAPhyEventCounters(sataPhyOutput)
+	assert.Len(t, counters, 17)
+	assert.Equal(t, 0x0009, counters[8].ID)
+	assert.Equal(t, int64(14), counters[8].Value)
+	assert.Equal(t, "Transition from drive PhyRdy to drive PhyNRdy", counters[8].Description)
+	assert.Equal(t, "sataphy_comreset_count", counters[9].fieldName())
+	assert.Equal(t, int64(9), counters[9].Value)
+	assert.Equal(t, 0x8000, counters[16].ID)
+	assert.Equal(t, 4, counters[16].Size)
+	assert.Equal(t, true, counters[16].Saturated)
+	assert.Equal(t, "sataphy_0x8000", counters[16].fieldName())
+
+	fields := sataPhyFields(counters[8:11], map[string]int64{
+		"sataphy_phyrdy_to_phynrdy":  10,
+		"sataphy_comreset_count":     12,
+		"sataphy_h2d_fis_crc_errors": 3,
+	})
+	assert.Equal(t, []string{
+		"sataphy_phyrdy_to_phynrdy=14",
+		"sataphy_phyrdy_to_phynrdy_delta=4",
+		"sataphy_comreset_count=9",
+		"sataphy_h2d_fis_crc_errors=3",
+		"sataphy_h2d_fis_crc_errors_delta=0",
+	}, fields)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sctTemperature holds the SCT temperature status and history of an ATA
+// drive, in degrees Celsius. Values the drive does not report are nil.
+type sctTemperature struct {
+	Current         *int
+	PowerCycleMin   *int
+	PowerCycleMax   *int
+	LifetimeMin     *int
+	LifetimeMax     *int
+	UnderLimitCount *int
+	OverLimitCount  *int
+	RecommendedMin  *int
+	RecommendedMax  *int
+	LimitMin        *int
+	LimitMax        *int
+	History         []int
+	// Unrecognised are the lines that are neither parsed nor known to be
+	// irrelevant
+	Unrecognised []string
+}
+
+var (
+	sctMinMaxRgx     = regexp.MustCompile(`^(-?\d+|-+)/(-?\d+|-+)`)
+	sctCurrentRgx    = regexp.MustCompile(`^(-?\d+) Celsius`)
+	sctHistoryRowRgx = regexp.MustCompile(`^\d+\s+\d{4}-\d{2}-\d{2} \d{2}:\d{2}\s+(-?\d+|\?)`)
+	// sctIgnoredRgx matches the lines not worth reporting: versions, state,
+	// sampling settings, vendor specific bytes and skipped history rows
+	sctIgnoredRgx = regexp.MustCompile(`^(SCT (Status |Temperature History )?Version|SCT Support Level|Device State|Vendor specific:|([0-9a-f]{2} )*[0-9a-f]{2}$|Temperature (Sampling Period|Logging Interval|History Size)|Index\s+Estimated Time|\.\.\.\s+\.\.\()`)
+)
+
+func parseSCTTemperature(out string) *sctTemperature {
+	temp := &sctTemperature{}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if m := sctHistoryRowRgx.FindStringSubmatch(line); m != nil {
+			if value, err := strconv.Atoi(m[1]); err == nil {
+				temp.History = append(temp.History, value)
+			}
+			continue
+		}
+
+		if smartctlBoilerplate(line) || sctIgnoredRgx.MatchString(line) {
+			continue
+		}
+		sliced := strings.SplitN(line, ":", 2)
+		if len(sliced) != 2 {
+			temp.Unrecognised = append(temp.Unrecognised, line)
+			continue
+		}
+		value := strings.TrimSpace(sliced[1])
+		switch columnsRgx.ReplaceAllString(sliced[0], " ") {
+		case "Current Temperature":
+			if m := sctCurrentRgx.FindStringSubmatch(value); m != nil {
+				temp.Current = atoiPtr(m[1])
+			}
+		case "Power Cycle Min/Max Temperature":
+			temp.PowerCycleMin, temp.PowerCycleMax = parseSCTMinMax(value)
+		case "Lifetime Min/Max Temperature":
+			temp.LifetimeMin, temp.LifetimeMax = parseSCTMinMax(value)
+		case "Under/Over Temperature Limit Count":
+			temp.UnderLimitCount, temp.OverLimitCount = parseSCTMinMax(value)
+		case "Min/Max recommended Temperature":
+			temp.RecommendedMin, temp.RecommendedMax = parseSCTMinMax(value)
+		case "Min/Max Temperature Limit":
+			temp.LimitMin, temp.LimitMax = parseSCTMinMax(value)
+		default:
+			temp.Unrecognised = append(temp.Unrecognised, line)
+		}
+	}
+	return temp
+}
+
+func parseSCTMinMax(value string) (*int, *int) {
+	m := sctMinMaxRgx.FindStringSubmatch(value)
+	if m == nil {
+		return nil, nil
+	}
+	return atoiPtr(m[1]), atoiPtr(m[2])
+}
+
+func atoiPtr(s string) *int {
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+// thresholds returns the warning and critical temperatures to apply, falling
+// back to the drive's recommended maximum and its limit when not configured.
+func (temp *sctTemperature) thresholds(warning, critical int) (int, int) {
+	if warning == 0 && temp.RecommendedMax != nil {
+		warning = *temp.RecommendedMax
+	}
+	if critical == 0 && temp.LimitMax != nil {
+		critical = *temp.LimitMax
+	}
+	return warning, critical
+}
+
+// status classifies the current temperature as ok, warning or critical, or
+// returns an empty string when it cannot be told.
+func (temp *sctTemperature) status(warning, critical int) string {
+	if temp.Current == nil || warning == 0 && critical == 0 {
+		return ""
+	}
+	switch {
+	case critical != 0 && *temp.Current >= critical:
+		return "critical"
+	case warning != 0 && *temp.Current >= warning:
+		return "warning"
+	}
+	return "ok"
+}
+
+func (temp *sctTemperature) fields(warning, critical int) []string {
+	fields := []string{}
+	for _, f := range []struct {
+		name  string
+		value *int
+	}{
+		{"sct_temperature", temp.Current},
+		{"sct_power_cycle_min_temperature", temp.PowerCycleMin},
+		{"sct_power_cycle_max_temperature", temp.PowerCycleMax},
+		{"sct_lifetime_min_temperature", temp.LifetimeMin},
+		{"sct_lifetime_max_temperature", temp.LifetimeMax},
+		{"sct_under_temperature_limit_count", temp.UnderLimitCount},
+		{"sct_over_temperature_limit_count", temp.OverLimitCount},
+		{"sct_recommended_min_temperature", temp.RecommendedMin},
+		{"sct_recommended_max_temperature", temp.RecommendedMax},
+		{"sct_min_temperature_limit", temp.LimitMin},
+		{"sct_max_temperature_limit", temp.LimitMax},
+	} {
+		if f.value != nil {
+			fields = append(fields, field(f.name, *f.value))
+		}
+	}
+
+	if len(temp.History) > 0 {
+		max, sum := temp.History[0], 0
+		for _, value := range temp.History {
+			if value > max {
+				max = value
+			}
+			sum += value
+		}
+		fields = append(fields,
+			field("sct_history_max_temperature", max),
+			field("sct_history_avg_temperature", float64(sum)/float64(len(temp.History))))
+	}
+
+	warning, critical = temp.thresholds(warning, critical)
+	if status := temp.status(warning, critical); status != "" {
+		fields = append(fields,
+			field("temperature_warning", warning),
+			field("temperature_critical", critical),
+			field("temperature_status", status))
+	}
+	return fields
+}
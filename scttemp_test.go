@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sctTempOutput = `
+smartctl 6.2 2013-07-26 r3841 [x86_64-linux-4.4.0-47-generic] (local build)
+Copyright (C) 2002-13, Bruce Allen, Christian Franke, www.smartmontools.org
+
+=== START OF READ SMART DATA SECTION ===
+SCT Status Version:                  3
+SCT Version (vendor specific):       256 (0x0100)
+SCT Support Level:                   1
+Device State:                        Active (0)
+Current Temperature:                    38 Celsius
+Power Cycle Min/Max Temperature:     24/45 Celsius
+Lifetime    Min/Max Temperature:     20/55 Celsius
+Under/Over Temperature Limit Count:   0/3
+Vendor specific:
+01 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+
+SCT Temperature History Version:     2
+Temperature Sampling Period:         1 minute
+Temperature Logging Interval:        1 minute
+Min/Max recommended Temperature:      0/60 Celsius
+Min/Max Temperature Limit:           -40/70 Celsius
+Temperature History Size (Index):    128 (35)
+
+Index    Estimated Time   Temperature Celsius
+  36    2017-06-27 08:14     ?  -
+  37    2017-06-27 08:15    36  *****************
+ ...    ..( 88 skipped).    ..  *****************
+ 126    2017-06-27 09:44    37  ******************
+ 127    2017-06-27 09:45    38  *******************
+   0    2017-06-27 09:46    41  **********************
+
+`
+
+func TestParseSCTTemperature(t *testing.T) {
+	temp := parseSCTTemperature(sctTempOutput)
+	assert.Equal(t, 38, *temp.Current)
+	assert.Equal(t, 24, *temp.PowerCycleMin)
+	assert.Equal(t, 45, *temp.PowerCycleMax)
+	assert.Equal(t, 20, *temp.LifetimeMin)
+	assert.Equal(t, 55, *temp.LifetimeMax)
+	assert.Equal(t, 0, *temp.UnderLimitCount)
+	assert.Equal(t, 3, *temp.OverLimitCount)
+	assert.Equal(t, 0, *temp.RecommendedMin)
+	assert.Equal(t, 60, *temp.RecommendedMax)
+	assert.Equal(t, -40, *temp.LimitMin)
+	assert.Equal(t, 70, *temp.LimitMax)
+	assert.Equal(t, []int{36, 37, 38, 41}, temp.History)
+
+	warning, critical := temp.thresholds(0, 0)
+	assert.Equal(t, 60, warning)
+	assert.Equal(t, 70, critical)
+	assert.Equal(t, "ok", temp.status(warning, critical))
+
+	warning, critical = temp.thresholds(38, 0)
+	assert.Equal(t, "warning", temp.status(warning, critical))
+	warning, critical = temp.thresholds(35, 38)
+	assert.Equal(t, "critical", temp.status(warning, critical))
+
+	fields := temp.fields(0, 0)
+	assert.Contains(t, fields, "sct_temperature=38")
+	assert.Contains(t, fields, "sct_history_max_temperature=41")
+	assert.Contains(t, fields, "sct_history_avg_temperature=38")
+	assert.Contains(t, fields, `temperature_status="ok"`)
+}
+
+func TestParseSCTTemperatureUnknownMinMax(t *testing.T) {
+	temp := parseSCTTemperature(`
+Current Temperature:                    31 Celsius
+Power Cycle Min/Max Temperature:     --/31 Celsius
+Lifetime    Min/Max Temperature:     --/52 Celsius
+`)
+	assert.Equal(t, 31, *temp.Current)
+	assert.Nil(t, temp.PowerCycleMin)
+	assert.Equal(t, 31, *temp.PowerCycleMax)
+	assert.Nil(t, temp.RecommendedMax)
+	assert.Equal(t, "", temp.status(temp.thresholds(0, 0)))
+}
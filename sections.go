@@ -0,0 +1,57 @@
+package main
+
+import "strings"
+
+// smartctlSectionHeaders maps the line starting each part of smartctl's
+// output to the section it belongs to. An empty name starts an anonymous
+// section, such as the banner or a header common to several sections.
+var smartctlSectionHeaders = []struct {
+	Prefix string
+	Name   string
+}{
+	{"=== START OF INFORMATION SECTION ===", "info"},
+	{"=== START OF READ SMART DATA SECTION ===", ""},
+	{"=== START OF SMART DATA SECTION ===", ""},
+	{"SMART overall-health self-assessment test result:", "health"},
+	{"SMART Health Status:", "health"},
+	{"Current Drive Temperature:", "scsiattributes"},
+	{"Drive Trip Temperature:", "scsiattributes"},
+	{"Manufactured in week", "scsiattributes"},
+	{"Elements in grown defect list:", "scsiattributes"},
+	{"General SMART Values:", "capabilities"},
+	{"SMART Attributes Data Structure revision number:", "attributes"},
+	{"Vendor Specific SMART Attributes with Thresholds:", "attributes"},
+	{"General Purpose Log Directory", "logdir"},
+	{"SMART Log Directory", "logdir"},
+	{"SMART Error Log", "errorlog"},
+	{"SMART Extended Comprehensive Error Log", "errorlog"},
+	{"SMART Self-test log", "selftestlog"},
+	{"SMART Extended Self-test Log", "selftestlog"},
+	{"SMART Selective self-test log", "selectivelog"},
+	{"SCT Status Version:", "scttemp"},
+	{"SCT Temperature History Version:", "scttemp"},
+	{"SCT Error Recovery Control", "scterc"},
+	{"Device Statistics (", "devstat"},
+	{"Pending Defects log", "pending"},
+	{"SATA Phy Event Counters", "sataphy"},
+	{"Background scan results log", "background"},
+}
+
+// splitSmartctlSections splits the output of a single smartctl run with
+// several options, e.g. -i -H -A -l devstat, into the part each option
+// printed, for the parser of that part.
+func splitSmartctlSections(out string) map[string]string {
+	sections := map[string]string{}
+	name := ""
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		for _, header := range smartctlSectionHeaders {
+			if strings.HasPrefix(trimmed, header.Prefix) {
+				name = header.Name
+				break
+			}
+		}
+		sections[name] += line + "\n"
+	}
+	return sections
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sectionParsers = map[string]func(string) interface{}{
+	"info":         func(out string) interface{} { return parseSMARTCtlInfo(out) },
+	"attributes":   func(out string) interface{} { parsed, _ := parseAttributeList(out); return parsed },
+	"capabilities": func(out string) interface{} { return parseSelfTestStatus(out) },
+	"devstat":      func(out string) interface{} { parsed, _ := parseDeviceStatistics(out); return parsed },
+	"scttemp":      func(out string) interface{} { return parseSCTTemperature(out) },
+	"sataphy":      func(out string) interface{} { parsed, _ := parseSATAPhyEventCounters(out); return parsed },
+	"background":   func(out string) interface{} { return parseBackgroundScan(out) },
+}
+
+var sectionFixtures = []struct {
+	Section string
+	Output  string
+}{
+	{"info", diskInfoOutput},
+	{"info", ssdDiskInfoOutput},
+	{"info", raidVolumeInfoOutput},
+	{"info", ccissInfoOutput},
+	{"info", aacraidInfoOutput},
+	{"info", usbUnsupportedInfoOutput},
+	{"info", usbSATInfoOutput},
+	{"attributes", diskAttributesOutput},
+	{"attributes", ssdDiskAttributesOutput},
+	{"attributes", hwRAIDDiskAttributesOutput},
+	{"capabilities", selfTestRunningOutput},
+	{"devstat", devStatOutput},
+	{"scttemp", sctTempOutput},
+	{"sataphy", sataPhyOutput},
+	{"background", backgroundOutput},
+	{"background", emptyBackgroundOutput},
+}
+
+// sectionOutput returns what the parser of the given section is given, the
+// health line being parsed along with the information section.
+func sectionOutput(sections map[string]string, name string) string {
+	if name == "info" {
+		return sections["info"] + sections["health"]
+	}
+	return sections[name]
+}
+
+func TestSplitSmartctlSections(t *testing.T) {
+	for i, fixture := range sectionFixtures {
+		sections := splitSmartctlSections(fixture.Output)
+		parse := sectionParsers[fixture.Section]
+		assert.Equal(t, parse(fixture.Output), parse(sectionOutput(sections, fixture.Section)), "fixture %d", i)
+	}
+}
+
+func TestSplitCombinedSmartctlOutput(t *testing.T) {
+	// what a single smartctl -i -H -c -A -l devstat -l scttemp -l sataphy
+	// run prints, banner aside
+	parts := []struct {
+		Section string
+		Output  string
+	}{
+		{"info", diskInfoOutput},
+		{"capabilities", selfTestRunningOutput},
+		{"attributes", diskAttributesOutput},
+		{"devstat", devStatOutput},
+		{"scttemp", sctTempOutput},
+		{"sataphy", sataPhyOutput},
+	}
+	combined := []string{}
+	for i, part := range parts {
+		lines := strings.Split(part.Output, "\n")
+		for _, line := range lines {
+			// smartctl prints its banner only once
+			if i > 0 && (strings.HasPrefix(line, "smartctl ") || strings.HasPrefix(line, "Copyright ")) {
+				continue
+			}
+			combined = append(combined, line)
+		}
+	}
+	sections := splitSmartctlSections(strings.Join(combined, "\n"))
+	for _, part := range parts {
+		parse := sectionParsers[part.Section]
+		assert.Equal(t, parse(part.Output), parse(sectionOutput(sections, part.Section)), part.Section)
+	}
+	attrs, _ := parseAttributeList(sections["attributes"])
+	assert.Len(t, attrs, 17)
+	assert.NotContains(t, sections["attributes"], "Device Statistics")
+	assert.Equal(t, "PASSED", parseSMARTCtlInfo(sectionOutput(sections, "info")).Health)
+}
+
+var sasDiskOutput = `
+smartctl 6.6 2016-05-31 r4324 [x86_64-linux-4.15.0-96-generic] (local build)
+Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
+
+=== START OF INFORMATION SECTION ===
+Vendor:               SEAGATE
+Product:              ST4000NM0023
+Revision:             0004
+Compliance:           SPC-4
+User Capacity:        4,000,787,030,016 bytes [4.00 TB]
+Logical block size:   512 bytes
+LU is fully provisioned
+Rotation Rate:        7200 rpm
+Form Factor:          3.5 inches
+Logical Unit id:      0x5000c50057d4b2d3
+Serial number:        Z1Z0ABCD0000C4281234
+Device type:          disk
+Transport protocol:   SAS (SPL-3)
+Local Time is:        Tue Jun 27 10:21:29 2017 CEST
+SMART support is:     Available - device has SMART capability.
+SMART support is:     Enabled
+Temperature Warning:  Enabled
+
+=== START OF READ SMART DATA SECTION ===
+SMART Health Status: OK
+
+Current Drive Temperature:     31 C
+Drive Trip Temperature:        68 C
+
+Manufactured in week 14 of year 2016
+Specified cycle count over device lifetime:  10000
+Accumulated start-stop cycles:  37
+Specified load-unload count over device lifetime:  300000
+Accumulated load-unload cycles:  553
+Elements in grown defect list: 0
+
+Vendor (Seagate) cache information
+  Blocks sent to initiator = 1234567890
+  Blocks received from initiator = 987654321
+`
+
+func TestSplitSASSmartctlOutput(t *testing.T) {
+	sections := splitSmartctlSections(sasDiskOutput)
+	assert.Equal(t, "SMART Health Status: OK\n\n", sections["health"])
+	assert.Contains(t, sections["scsiattributes"], "Elements in grown defect list: 0")
+	assert.Contains(t, sections["scsiattributes"], "Blocks sent to initiator = 1234567890")
+
+	info := parseSMARTCtlInfo(sectionOutput(sections, "info"))
+	assert.Equal(t, "ST4000NM0023", info.Product)
+	assert.Equal(t, "Z1Z0ABCD0000C4281234", info.SerialNumber)
+	assert.Equal(t, "OK", info.Health)
+	assert.True(t, info.Healthy)
+	assert.Empty(t, info.Unrecognised)
+	assert.Empty(t, sectionCoverage(sections))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type selfTestStatus struct {
+	Code         int
+	Running      bool
+	RemainingPct int
+	Description  string
+}
+
+var selfTestStatusRgx = regexp.MustCompile(`^Self-test execution status:\s+\(\s*(\d+)\)\s*(.*)$`)
+
+// parseSelfTestStatus reads the self-test execution status from the output of
+// smartctl -c, returning nil if the drive does not report one.
+func parseSelfTestStatus(out string) *selfTestStatus {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var status *selfTestStatus
+	for _, line := range lines {
+		if status != nil {
+			// the description continues on lines starting with tabs
+			if !strings.HasPrefix(line, "\t") || strings.Contains(line, ":") {
+				break
+			}
+			status.Description += " " + strings.TrimSpace(line)
+			continue
+		}
+		if m := selfTestStatusRgx.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			code, _ := strconv.Atoi(m[1])
+			status = &selfTestStatus{
+				Code:        code,
+				Running:     code>>4 == 0xf,
+				Description: strings.TrimSpace(m[2]),
+			}
+			if status.Running {
+				status.RemainingPct = (code & 0xf) * 10
+			}
+		}
+	}
+	if status != nil {
+		status.Description = strings.TrimSpace(status.Description)
+	}
+	return status
+}
+
+// selfTestDue tells whether a test repeating every period should start now,
+// given when it last started. Each key gets its own offset within the period,
+// so that the disks of a host or an array do not all start testing at once.
+func selfTestDue(every time.Duration, key string, last, now time.Time) bool {
+	if every <= 0 {
+		return false
+	}
+	offset := selfTestOffset(every, key)
+	slotStart := now.Add(-offset).Truncate(every).Add(offset)
+	return last.Before(slotStart)
+}
+
+// selfTestOffset spreads keys evenly over the period, by scaling their hash
+// to it. The keys of a host's disks differ by a character or two, which
+// FNV and other cheap hashes barely spread over their high bits.
+func selfTestOffset(every time.Duration, key string) time.Duration {
+	sum := sha256.Sum256([]byte(key))
+	return time.Duration(float64(binary.BigEndian.Uint32(sum[:4])) / (1 << 32) * float64(every))
+}
+
+// selfTestRecord is what is kept between runs about the self-tests of a disk.
+type selfTestRecord struct {
+	// Running is the test started by the checker and still in progress
+	Running string `json:"running,omitempty"`
+	// Short and Long are when each test was last started, in Unix time
+	Short int64 `json:"short"`
+	Long  int64 `json:"long"`
+}
+
+// due returns the test to start now, if any. A long test is preferred when
+// both are due, as it also does what the short one does.
+func (record *selfTestRecord) due(key string, shortEvery, longEvery time.Duration, now time.Time) string {
+	if selfTestDue(longEvery, key, time.Unix(record.Long, 0), now) {
+		return "long"
+	}
+	if selfTestDue(shortEvery, key, time.Unix(record.Short, 0), now) {
+		return "short"
+	}
+	return ""
+}
+
+func (record *selfTestRecord) started(test string, now time.Time) {
+	record.Running = test
+	record.Short = now.Unix()
+	if test == "long" {
+		record.Long = now.Unix()
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var selfTestRunningOutput = `
+=== START OF READ SMART DATA SECTION ===
+General SMART Values:
+Offline data collection status:  (0x82)	Offline data collection activity
+					was completed without error.
+					Auto Offline Data Collection: Enabled.
+Self-test execution status:      ( 249)	Self-test routine in progress...
+					90% of test remaining.
+Total time to complete Offline 
+data collection: 		(  113) seconds.
+`
+
+func TestParseSelfTestStatusRunning(t *testing.T) {
+	status := parseSelfTestStatus(selfTestRunningOutput)
+	assert.NotNil(t, status)
+	assert.Equal(t, 249, status.Code)
+	assert.Equal(t, true, status.Running)
+	assert.Equal(t, 90, status.RemainingPct)
+	assert.Equal(t, "Self-test routine in progress... 90% of test remaining.", status.Description)
+}
+
+var selfTestIdleOutput = `
+Self-test execution status:      (   0)	The previous self-test routine completed
+					without error or no self-test has ever 
+					been run.
+Total time to complete Offline 
+`
+
+func TestParseSelfTestStatusIdle(t *testing.T) {
+	status := parseSelfTestStatus(selfTestIdleOutput)
+	assert.NotNil(t, status)
+	assert.Equal(t, false, status.Running)
+	assert.Equal(t, 0, status.RemainingPct)
+	assert.Equal(t, "The previous self-test routine completed without error or no self-test has ever been run.", status.Description)
+
+	assert.Nil(t, parseSelfTestStatus("SMART support is: Unavailable - device lacks SMART capability."))
+}
+
+func TestSelfTestDue(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Date(2017, 6, 27, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, selfTestDue(day, "db01 /dev/sda auto", time.Time{}, now))
+	assert.False(t, selfTestDue(day, "db01 /dev/sda auto", now, now))
+	assert.False(t, selfTestDue(0, "db01 /dev/sda auto", time.Time{}, now))
+
+	// exactly one test per period, wherever the offset falls
+	for _, key := range []string{"db01 /dev/sda auto", "db01 /dev/bus/4 megaraid,14", "db01 /dev/bus/4 megaraid,15"} {
+		last := now
+		started := 0
+		for at := now.Add(time.Hour); !at.After(now.Add(10 * day)); at = at.Add(time.Hour) {
+			if selfTestDue(day, key, last, at) {
+				last = at
+				started++
+			}
+		}
+		assert.Equal(t, 10, started, key)
+	}
+}
+
+func TestSelfTestOffset(t *testing.T) {
+	day := 24 * time.Hour
+	hours := map[time.Duration]bool{}
+	var min, max time.Duration = day, 0
+	for i := 0; i < 16; i++ {
+		offset := selfTestOffset(day, fmt.Sprintf("db01 /dev/bus/4 megaraid,%d", i))
+		assert.True(t, offset >= 0 && offset < day)
+		hours[offset/time.Hour] = true
+		if offset < min {
+			min = offset
+		}
+		if offset > max {
+			max = offset
+		}
+	}
+	assert.True(t, max-min > day/2, "offsets between %s and %s", min, max)
+	assert.True(t, len(hours) >= 8, "offsets in %d distinct hours", len(hours))
+}
+
+func TestSelfTestRecordDue(t *testing.T) {
+	now := time.Date(2017, 6, 27, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	key := "db01 /dev/sda auto"
+
+	record := &selfTestRecord{}
+	assert.Equal(t, "long", record.due(key, day, 30*day, now))
+	record.started("long", now)
+	assert.Equal(t, "long", record.Running)
+	assert.Equal(t, now.Unix(), record.Short)
+	assert.Equal(t, "", record.due(key, day, 30*day, now.Add(time.Hour)))
+	assert.Equal(t, "short", record.due(key, day, 30*day, now.Add(2*day)))
+	assert.Equal(t, "", record.due(key, 0, 0, now.Add(2*day)))
+}
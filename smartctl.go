@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -12,6 +13,15 @@ type deviceInfo struct {
 	Raw  string
 	Path string
 	Type string
+	// Args are extra smartctl arguments, see overrideDB
+	Args []string
+}
+
+// args returns the smartctl arguments to run the given options against the
+// device.
+func (device deviceInfo) args(options ...string) []string {
+	args := append(append([]string{}, options...), device.Args...)
+	return append(args, device.Path, "-d", device.Type)
 }
 
 var deviceInfoRgx = regexp.MustCompile(`(\S+) \-d (\S+)`)
@@ -28,6 +38,10 @@ func parseSMARTCtlScan(out string) []deviceInfo {
 			if di.Type == "scsi" {
 				di.Type = "auto"
 			}
+			if !validDevicePath(di.Path) || !validDeviceType(di.Type) {
+				log.Printf("Ignoring scanned device %q: not a device path and type", strings.TrimSpace(line))
+				continue
+			}
 			devices = append(devices, di)
 		}
 	}
@@ -49,6 +63,8 @@ type smartCtlInfo struct {
 	IsSSD                 bool
 	ATAVersion            string
 	SATAVersion           string
+	SATAMaxSpeedGbps      float64
+	SATACurrentSpeedGbps  float64
 	SMARTSupportIs        string
 	SMARTSupport          bool
 	Vendor                string
@@ -58,8 +74,12 @@ type smartCtlInfo struct {
 	LogicalBlockSizeBytes int
 	LogicalUnitID         string
 	DeviceType            string
+	TransportProtocol     string
 	Health                string
 	Healthy               bool
+	OpenError             string
+	// Unrecognised are the lines of smartctl's output that were not parsed
+	Unrecognised []string
 }
 
 var (
@@ -68,7 +88,9 @@ var (
 	userCapacityRgx            = regexp.MustCompile(`^([,\d]+) bytes \[.+?\]$`)
 	rpmRgx                     = regexp.MustCompile(`^([\d]+) rpm$`)
 	bytesRgx                   = regexp.MustCompile(`^(\d+) bytes$`)
+	sataSpeedRgx               = regexp.MustCompile(`, ([\d.]+) Gb/s(?: \(current: ([\d.]+) Gb/s\))?`)
 	columnsRgx                 = regexp.MustCompile(`\s+`)
+	attributeHeaderRgx         = regexp.MustCompile(`^(SMART Attributes Data Structure revision number|Vendor Specific SMART Attributes with Thresholds)`)
 	influxDBFieldNameFilterRgx = regexp.MustCompile(`[^A-Za-z0-9]`)
 )
 
@@ -80,7 +102,7 @@ func parseSMARTCtlInfo(out string) *smartCtlInfo {
 		switch sliced[0] {
 		case "Device Model":
 			info.DeviceModel = strings.TrimSpace(sliced[1])
-		case "Serial Number":
+		case "Serial Number", "Serial number":
 			info.SerialNumber = strings.TrimSpace(sliced[1])
 		case "LU WWN Device Id":
 			info.LUWWNDeviceID = strings.TrimSpace(sliced[1])
@@ -113,6 +135,10 @@ func parseSMARTCtlInfo(out string) *smartCtlInfo {
 			info.ATAVersion = strings.TrimSpace(sliced[1])
 		case "SATA Version is":
 			info.SATAVersion = strings.TrimSpace(sliced[1])
+			if m := sataSpeedRgx.FindStringSubmatch(info.SATAVersion); m != nil {
+				info.SATAMaxSpeedGbps, _ = strconv.ParseFloat(m[1], 64)
+				info.SATACurrentSpeedGbps, _ = strconv.ParseFloat(m[2], 64)
+			}
 		case "SMART support is":
 			info.SMARTSupportIs = strings.TrimSpace(sliced[1])
 			info.SMARTSupport = info.SMARTSupportIs == "Enabled"
@@ -131,15 +157,63 @@ func parseSMARTCtlInfo(out string) *smartCtlInfo {
 			info.LogicalUnitID = strings.TrimSpace(sliced[1])
 		case "Device type":
 			info.DeviceType = strings.TrimSpace(sliced[1])
+		case "Transport protocol":
+			info.TransportProtocol = strings.TrimSpace(sliced[1])
 		case "SMART overall-health self-assessment test result":
 			info.Health = strings.TrimSpace(sliced[1])
 			info.Healthy = info.Health == "PASSED"
+		case "SMART Health Status":
+			info.Health = strings.TrimSpace(sliced[1])
+			info.Healthy = info.Health == "OK"
+		case "Smartctl open device":
+			info.OpenError = strings.TrimSpace(sliced[1])
+		case "Model Family", "Device is", "Local Time is", "Form Factor", "Compliance", "Add. Product Id", "Temperature Warning",
+			"LU is fully provisioned", "LU is resource provisioned", "LU is thin provisioned":
+			// known, but of no use to the checker
+		default:
+			if !smartctlBoilerplate(line) {
+				info.Unrecognised = append(info.Unrecognised, line)
+			}
 		}
 	}
 
 	return info
 }
 
+// smartctlBoilerplate tells whether the line is one smartctl prints whatever
+// the device, such as its banner, a section header or a blank line.
+func smartctlBoilerplate(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "===") || strings.HasPrefix(line, "smartctl ") || strings.HasPrefix(line, "Copyright (C)")
+}
+
+// recognised tells whether anything identifying the device, or why it could
+// not be opened, was parsed, which is not the case when smartctl printed its
+// output in an unexpected language or format.
+func (info *smartCtlInfo) recognised() bool {
+	return info.OpenError != "" || info.DeviceModel != "" || info.Product != "" || info.SerialNumber != "" || info.SMARTSupportIs != "" || info.Healthy
+}
+
+// sataLinkDegraded tells whether the SATA link negotiated a lower speed than
+// the drive supports, which usually points at a bad cable or backplane.
+func (info *smartCtlInfo) sataLinkDegraded() bool {
+	return info.SATACurrentSpeedGbps > 0 && info.SATACurrentSpeedGbps < info.SATAMaxSpeedGbps
+}
+
+// isSAS tells whether the drive is a SAS one.
+func (info *smartCtlInfo) isSAS() bool {
+	return strings.HasPrefix(info.TransportProtocol, "SAS")
+}
+
+// modelAndFirmware returns the model and firmware version of ATA drives, or
+// the product and revision of SCSI ones.
+func (info *smartCtlInfo) modelAndFirmware() (string, string) {
+	if info.DeviceModel != "" {
+		return info.DeviceModel, info.FirmwareVersion
+	}
+	return info.Product, info.Revision
+}
+
 type smartAttribute struct {
 	ID            int    ``
 	Name          string ``
@@ -150,8 +224,9 @@ type smartAttribute struct {
 	Type          string `type:"detail" escape:"true"`
 	Updated       string `type:"detail" escape:"true"`
 	WhenFailed    string `type:"detail" name:"When_Failed" escape:"true"`
-	RawValue      int    `type:"value" name:"Raw_Value"`
+	RawValue      int64  `type:"value" name:"Raw_Value"`
 	RawValueNotes string `type:"detail" name:"Raw_Value_Notes" escape:"true"`
+	RawString     string ``
 }
 
 func newSmartAttribute(columns []string) *smartAttribute {
@@ -166,7 +241,8 @@ func newSmartAttribute(columns []string) *smartAttribute {
 	attrib.Type = columns[6]
 	attrib.Updated = columns[7]
 	attrib.WhenFailed = columns[8]
-	attrib.RawValue, _ = strconv.Atoi(columns[9])
+	attrib.RawString = columns[9]
+	attrib.RawValue, _ = strconv.ParseInt(columns[9], 10, 64)
 	if len(columns) > 10 {
 		attrib.RawValueNotes = columns[10]
 	}
@@ -216,19 +292,33 @@ func (attr smartAttribute) String(useNames, detailed bool) string {
 	return strings.Join(kvs, ",")
 }
 
-func parseAttributeList(out string) []*smartAttribute {
+// parseAttributeList parses the attribute table printed by smartctl -A. It
+// also returns the lines that are neither the table nor its headers.
+func parseAttributeList(out string) ([]*smartAttribute, []string) {
 	lines := strings.Split(strings.TrimSpace(out), "\n")
 	var inTable bool
 	attributes := make([]*smartAttribute, 0, len(lines)) // 7 is the usual header size
+	unrecognised := []string{}
 	for _, line := range lines {
 		if strings.HasPrefix(line, "ID#") {
 			inTable = true
 			continue
 		}
 		if inTable {
+			// the table ends at the first blank line, before the next log
+			if strings.TrimSpace(line) == "" {
+				inTable = false
+				continue
+			}
 			columns := columnsRgx.Split(strings.TrimSpace(line), 11)
-			attributes = append(attributes, newSmartAttribute(columns))
+			if len(columns) >= 10 {
+				attributes = append(attributes, newSmartAttribute(columns))
+				continue
+			}
+		}
+		if !smartctlBoilerplate(line) && !attributeHeaderRgx.MatchString(line) {
+			unrecognised = append(unrecognised, line)
 		}
 	}
-	return attributes
+	return attributes, unrecognised
 }
@@ -6,11 +6,12 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGetSMARTDevices(t *testing.T) {
-	var scanOutput = `
+var scanOutput = `
 /dev/sda -d scsi # /dev/sda, SCSI device
 /dev/sdb -d scsi # /dev/sdb, SCSI device
 `
+
+func TestGetSMARTDevices(t *testing.T) {
 	devices := parseSMARTCtlScan(scanOutput)
 	assert.NotEmpty(t, devices)
 	assert.Equal(t, "/dev/sda", devices[0].Path)
@@ -19,12 +20,13 @@ func TestGetSMARTDevices(t *testing.T) {
 	assert.Equal(t, "auto", devices[1].Type)
 }
 
-func TestGetSMARTDevicesOnHWRAID(t *testing.T) {
-	var scanHWRAIDOutput = `
+var scanHWRAIDOutput = `
 /dev/sda -d scsi # /dev/sda, SCSI device
 /dev/bus/4 -d megaraid,14 # /dev/bus/4 [megaraid_disk_14], SCSI device
 /dev/bus/4 -d megaraid,15 # /dev/bus/4 [megaraid_disk_15], SCSI device
 `
+
+func TestGetSMARTDevicesOnHWRAID(t *testing.T) {
 	devices := parseSMARTCtlScan(scanHWRAIDOutput)
 	assert.NotEmpty(t, devices)
 	assert.Equal(t, "/dev/sda", devices[0].Path)
@@ -35,8 +37,7 @@ func TestGetSMARTDevicesOnHWRAID(t *testing.T) {
 	assert.Equal(t, "megaraid,15", devices[2].Type)
 }
 
-func TestParseSMARTCtlInfo(t *testing.T) {
-	var diskInfoOutput = `
+var diskInfoOutput = `
 smartctl 6.2 2013-07-26 r3841 [x86_64-linux-4.4.0-47-generic] (local build)
 Copyright (C) 2002-13, Bruce Allen, Christian Franke, www.smartmontools.org
 
@@ -59,6 +60,8 @@ SMART support is: Enabled
 SMART overall-health self-assessment test result: PASSED
 
 `
+
+func TestParseSMARTCtlInfo(t *testing.T) {
 	info := parseSMARTCtlInfo(diskInfoOutput)
 	assert.Equal(t, "HGST HDN724040ALE640", info.DeviceModel)
 	assert.Equal(t, "PK2338P4H4XPXC", info.SerialNumber)
@@ -77,8 +80,7 @@ SMART overall-health self-assessment test result: PASSED
 	assert.Equal(t, true, info.SMARTSupport)
 }
 
-func TestParseSSDSMARTCtlInfo(t *testing.T) {
-	var ssdDiskInfoOutput = `
+var ssdDiskInfoOutput = `
 smartctl 6.2 2013-07-26 r3841 [x86_64-linux-3.16.0-77-generic] (local build)
 Copyright (C) 2002-13, Bruce Allen, Christian Franke, www.smartmontools.org
 
@@ -101,6 +103,8 @@ SMART support is: Enabled
 SMART overall-health self-assessment test result: PASSED
 
 `
+
+func TestParseSSDSMARTCtlInfo(t *testing.T) {
 	info := parseSMARTCtlInfo(ssdDiskInfoOutput)
 	assert.Equal(t, "INTEL SSDSC2BW480H6", info.DeviceModel)
 	assert.Equal(t, "CVTR527201W1480EGN", info.SerialNumber)
@@ -120,8 +124,7 @@ SMART overall-health self-assessment test result: PASSED
 	assert.Equal(t, true, info.SMARTSupport)
 }
 
-func TestParseRaidVolumeSMARTCtlInfo(t *testing.T) {
-	var raidVolumeInfoOutput = `
+var raidVolumeInfoOutput = `
 smartctl 6.2 2013-07-26 r3841 [x86_64-linux-3.10.0-327.10.1.el7.x86_64] (local build)
 Copyright (C) 2002-13, Bruce Allen, Christian Franke, www.smartmontools.org
 
@@ -139,6 +142,8 @@ SMART support is:     Unavailable - device lacks SMART capability.
 === START OF READ SMART DATA SECTION ===
 
 `
+
+func TestParseRaidVolumeSMARTCtlInfo(t *testing.T) {
 	info := parseSMARTCtlInfo(raidVolumeInfoOutput)
 	assert.Equal(t, "LSI", info.Vendor)
 	assert.Equal(t, "Logical Volume", info.Product)
@@ -155,8 +160,7 @@ SMART support is:     Unavailable - device lacks SMART capability.
 	assert.Equal(t, false, info.Healthy)
 }
 
-func TestParseDiskAttributesOutput(t *testing.T) {
-	var diskAttributesOutput = `
+var diskAttributesOutput = `
 smartctl 6.2 2013-07-26 r3841 [x86_64-linux-4.4.0-47-generic] (local build)
 Copyright (C) 2002-13, Bruce Allen, Christian Franke, www.smartmontools.org
 
@@ -183,7 +187,9 @@ ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_
 199 UDMA_CRC_Error_Count    0x000a   200   200   000    Old_age   Always       -       0
 
 `
-	attributes := parseAttributeList(diskAttributesOutput)
+
+func TestParseDiskAttributesOutput(t *testing.T) {
+	attributes, _ := parseAttributeList(diskAttributesOutput)
 	assert.NotNil(t, attributes)
 	assert.Len(t, attributes, 17)
 	assert.Equal(t, 194, attributes[12].ID)
@@ -195,12 +201,15 @@ ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_
 	assert.Equal(t, "Old_age", attributes[12].Type)
 	assert.Equal(t, "Always", attributes[12].Updated)
 	assert.Equal(t, "-", attributes[12].WhenFailed)
-	assert.Equal(t, 38, attributes[12].RawValue)
+	assert.Equal(t, int64(38), attributes[12].RawValue)
+
+	large, _ := parseAttributeList(`ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+241 Total_LBAs_Written      0x0032   099   099   000    Old_age   Always       -       60000000000`)
+	assert.Equal(t, int64(60000000000), large[0].RawValue)
 	assert.Equal(t, "(Min/Max 24/45)", attributes[12].RawValueNotes)
 }
 
-func TestParseSSDDiskAttributesOutput(t *testing.T) {
-	var ssdDiskAttributesOutput = `
+var ssdDiskAttributesOutput = `
 smartctl 6.2 2013-07-26 r3841 [x86_64-linux-3.16.0-77-generic] (local build)
 Copyright (C) 2002-13, Bruce Allen, Christian Franke, www.smartmontools.org
 
@@ -232,7 +241,9 @@ ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_
 249 Unknown_Attribute       0x0032   100   100   000    Old_age   Always       -       200932
 
 `
-	attributes := parseAttributeList(ssdDiskAttributesOutput)
+
+func TestParseSSDDiskAttributesOutput(t *testing.T) {
+	attributes, _ := parseAttributeList(ssdDiskAttributesOutput)
 	assert.NotNil(t, attributes)
 	assert.Len(t, attributes, 22)
 	assert.Equal(t, 199, attributes[12].ID)
@@ -244,12 +255,11 @@ ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_
 	assert.Equal(t, "Old_age", attributes[12].Type)
 	assert.Equal(t, "Always", attributes[12].Updated)
 	assert.Equal(t, "-", attributes[12].WhenFailed)
-	assert.Equal(t, 0, attributes[12].RawValue)
+	assert.Equal(t, int64(0), attributes[12].RawValue)
 	assert.Equal(t, "", attributes[12].RawValueNotes)
 }
 
-func TestParseHWRAIDDiskAttributesOutput(t *testing.T) {
-	var hwRAIDDiskAttributesOutput = `
+var hwRAIDDiskAttributesOutput = `
 smartctl 6.6 2016-05-31 r4324 [x86_64-linux-4.4.35-2-pve] (local build)
 Copyright (C) 2002-16, Bruce Allen, Christian Franke, www.smartmontools.org
 
@@ -283,7 +293,9 @@ ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_
 242 Total_LBAs_Read         0x0000   100   253   000    Old_age   Offline      -       54631063470
 
 `
-	attributes := parseAttributeList(hwRAIDDiskAttributesOutput)
+
+func TestParseHWRAIDDiskAttributesOutput(t *testing.T) {
+	attributes, _ := parseAttributeList(hwRAIDDiskAttributesOutput)
 	assert.NotNil(t, attributes)
 	assert.Len(t, attributes, 24)
 	assert.Equal(t, 189, attributes[12].ID)
@@ -295,6 +307,81 @@ ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_
 	assert.Equal(t, "Old_age", attributes[12].Type)
 	assert.Equal(t, "Always", attributes[12].Updated)
 	assert.Equal(t, "-", attributes[12].WhenFailed)
-	assert.Equal(t, 0, attributes[12].RawValue)
+	assert.Equal(t, int64(0), attributes[12].RawValue)
 	assert.Equal(t, "", attributes[12].RawValueNotes)
 }
+
+func TestParseSATALinkSpeed(t *testing.T) {
+	info := parseSMARTCtlInfo(`
+SATA Version is:  SATA 3.0, 6.0 Gb/s (current: 3.0 Gb/s)
+`)
+	assert.Equal(t, 6.0, info.SATAMaxSpeedGbps)
+	assert.Equal(t, 3.0, info.SATACurrentSpeedGbps)
+	assert.Equal(t, true, info.sataLinkDegraded())
+
+	info = parseSMARTCtlInfo(`
+SATA Version is:  SATA >3.1, 6.0 Gb/s (current: 6.0 Gb/s)
+`)
+	assert.Equal(t, 6.0, info.SATAMaxSpeedGbps)
+	assert.Equal(t, 6.0, info.SATACurrentSpeedGbps)
+	assert.Equal(t, false, info.sataLinkDegraded())
+
+	info = parseSMARTCtlInfo(`
+SATA Version is:  SATA 2.6, 3.0 Gb/s
+`)
+	assert.Equal(t, 3.0, info.SATAMaxSpeedGbps)
+	assert.Equal(t, 0.0, info.SATACurrentSpeedGbps)
+	assert.Equal(t, false, info.sataLinkDegraded())
+}
+
+func TestParseSASSMARTCtlInfo(t *testing.T) {
+	info := parseSMARTCtlInfo(`
+=== START OF INFORMATION SECTION ===
+Vendor:               SEAGATE
+Product:              ST4000NM0023
+Revision:             0004
+User Capacity:        4,000,787,030,016 bytes [4.00 TB]
+Logical block size:   512 bytes
+Rotation Rate:        7200 rpm
+Logical Unit id:      0x5000c50057d4b2d3
+Device type:          disk
+Transport protocol:   SAS (SPL-3)
+SMART support is:     Enabled
+`)
+	assert.Equal(t, "SAS (SPL-3)", info.TransportProtocol)
+	assert.Equal(t, true, info.isSAS())
+	assert.Equal(t, false, parseSMARTCtlInfo("Device type:          disk").isSAS())
+}
+
+func TestParseUnrecognisedSMARTCtlInfo(t *testing.T) {
+	info := parseSMARTCtlInfo(diskInfoOutput)
+	assert.Equal(t, true, info.recognised())
+	assert.Empty(t, info.Unrecognised)
+
+	var localizedInfoOutput = `
+smartctl 6.2 2013-07-26 r3841 [x86_64-linux-4.4.0-47-generic] (local build)
+Copyright (C) 2002-13, Bruce Allen, Christian Franke, www.smartmontools.org
+
+=== START OF INFORMATION SECTION ===
+Gerätemodell:     HGST HDN724040ALE640
+Seriennummer:     PK2338P4H4XPXC
+SMART-Unterstützung ist: Aktiviert
+
+=== START OF READ SMART DATA SECTION ===
+Ergebnis des SMART-Gesamtzustandstests: BESTANDEN
+`
+	info = parseSMARTCtlInfo(localizedInfoOutput)
+	assert.Equal(t, false, info.recognised())
+	assert.Equal(t, "UNSUPPORTED", info.Health)
+	assert.Equal(t, []string{
+		"Gerätemodell:     HGST HDN724040ALE640",
+		"Seriennummer:     PK2338P4H4XPXC",
+		"SMART-Unterstützung ist: Aktiviert",
+		"Ergebnis des SMART-Gesamtzustandstests: BESTANDEN",
+	}, info.Unrecognised)
+
+	assert.Equal(t, true, parseSMARTCtlInfo(raidVolumeInfoOutput).recognised())
+	info = parseSMARTCtlInfo(ccissMissingOutput)
+	assert.Equal(t, true, info.recognised())
+	assert.Equal(t, "/dev/sg0 [cciss_disk_02] [SCSI/SAT] failed: No such device", info.OpenError)
+}
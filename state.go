@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// stateStore keeps values between runs of the checker, so collectors can
+// report deltas since the previous run.
+type stateStore struct {
+	path    string
+	lock    *os.File
+	dirty   bool
+	Entries map[string]json.RawMessage `json:"entries"`
+}
+
+// loadState reads the state, holding a lock on it until release so that runs
+// of check and selftest at the same time do not lose each other's changes.
+func loadState(path string) (*stateStore, error) {
+	state := &stateStore{path: path, Entries: map[string]json.RawMessage{}}
+	if err := state.acquire(); err != nil {
+		return state, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		state.Entries = map[string]json.RawMessage{}
+		return state, err
+	}
+	if state.Entries == nil {
+		state.Entries = map[string]json.RawMessage{}
+	}
+	return state, nil
+}
+
+func (s *stateStore) acquire() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return err
+	}
+	s.lock = lock
+	return nil
+}
+
+// release unlocks the state, which cannot be saved anymore.
+func (s *stateStore) release() {
+	if s.lock != nil {
+		s.lock.Close()
+		s.lock = nil
+	}
+}
+
+// get decodes the value stored under key into v, returning false if there is
+// no usable value.
+func (s *stateStore) get(key string, v interface{}) bool {
+	raw, ok := s.Entries[key]
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(raw, v) == nil
+}
+
+func (s *stateStore) set(key string, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.Entries[key] = raw
+	s.dirty = true
+}
+
+// save writes the state to disk if anything changed since it was loaded.
+func (s *stateStore) save() error {
+	if !s.dirty {
+		return nil
+	}
+	if s.lock == nil {
+		return errors.New("not saving the state, it is not locked")
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk-health-checker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "state.json")
+
+	state, err := loadState(path)
+	assert.NoError(t, err)
+	var seq int64
+	assert.False(t, state.get("kmsg", &seq))
+
+	state.set("kmsg", int64(42))
+	assert.NoError(t, state.save())
+	state.release()
+
+	state, err = loadState(path)
+	assert.NoError(t, err)
+	defer state.release()
+	assert.True(t, state.get("kmsg", &seq))
+	assert.Equal(t, int64(42), seq)
+}
+
+func TestStateStoreLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk-health-checker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	first, err := loadState(path)
+	assert.NoError(t, err)
+	first.set("kmsg", int64(42))
+
+	loaded := make(chan *stateStore)
+	go func() {
+		second, err := loadState(path)
+		assert.NoError(t, err)
+		loaded <- second
+	}()
+	select {
+	case <-loaded:
+		t.Fatal("state loaded while locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, first.save())
+	first.release()
+	second := <-loaded
+	defer second.release()
+	var seq int64
+	assert.True(t, second.get("kmsg", &seq))
+	assert.Equal(t, int64(42), seq)
+
+	first.set("kmsg", int64(43))
+	assert.Error(t, first.save())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path, path + ".lock"}, files)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// storcliResponse is the envelope of storcli's (and perccli's) JSON output,
+// with one entry per controller.
+type storcliResponse struct {
+	Controllers []struct {
+		CommandStatus struct {
+			Controller  int    `json:"Controller"`
+			Status      string `json:"Status"`
+			Description string `json:"Description"`
+		} `json:"Command Status"`
+		ResponseData json.RawMessage `json:"Response Data"`
+	} `json:"Controllers"`
+}
+
+type megaraidController struct {
+	Basics struct {
+		Controller   int    `json:"Controller"`
+		Model        string `json:"Model"`
+		SerialNumber string `json:"Serial Number"`
+		PCIAddress   string `json:"PCI Address"`
+	} `json:"Basics"`
+	Version struct {
+		FirmwareVersion string `json:"Firmware Version"`
+	} `json:"Version"`
+	Status struct {
+		ControllerStatus          string `json:"Controller Status"`
+		MemoryCorrectableErrors   int    `json:"Memory Correctable Errors"`
+		MemoryUncorrectableErrors int    `json:"Memory Uncorrectable Errors"`
+	} `json:"Status"`
+	VDs        []*megaraidVD      `json:"VD LIST"`
+	PDs        []*megaraidPD      `json:"PD LIST"`
+	CacheVault []*megaraidBattery `json:"Cachevault_Info"`
+	BBU        []*megaraidBattery `json:"BBU_Info"`
+
+	PatrolRead string `json:"-"`
+	// Device is the /dev/bus/N path smartctl addresses the controller's
+	// drives through, if known
+	Device string `json:"-"`
+}
+
+type megaraidVD struct {
+	DGVD    string `json:"DG/VD"`
+	Type    string `json:"TYPE"`
+	State   string `json:"State"`
+	Access  string `json:"Access"`
+	Consist string `json:"Consist"`
+	Cache   string `json:"Cache"`
+	Size    string `json:"Size"`
+	Name    string `json:"Name"`
+}
+
+type megaraidPD struct {
+	EIDSlot string          `json:"EID:Slt"`
+	DID     int             `json:"DID"`
+	State   string          `json:"State"`
+	DG      json.RawMessage `json:"DG"`
+	Size    string          `json:"Size"`
+	Intf    string          `json:"Intf"`
+	Med     string          `json:"Med"`
+	Model   string          `json:"Model"`
+
+	MediaErrors       int    `json:"-"`
+	OtherErrors       int    `json:"-"`
+	PredictiveFailure int    `json:"-"`
+	SMARTAlert        string `json:"-"`
+}
+
+type megaraidBattery struct {
+	Model string `json:"Model"`
+	State string `json:"State"`
+	Temp  string `json:"Temp"`
+}
+
+// megaraidPDState is the state of a physical drive, as found in the detailed
+// output of /call/eall/sall show all.
+type megaraidPDState struct {
+	MediaErrors       int    `json:"Media Error Count"`
+	OtherErrors       int    `json:"Other Error Count"`
+	PredictiveFailure int    `json:"Predictive Failure Count"`
+	SMARTAlert        string `json:"S.M.A.R.T alert flagged by drive"`
+}
+
+func parseStorcliResponse(out string) (*storcliResponse, error) {
+	response := &storcliResponse{}
+	if err := json.Unmarshal([]byte(out), response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// parseStorcliControllers parses the output of storcli /call show all J.
+func parseStorcliControllers(out string) ([]*megaraidController, error) {
+	response, err := parseStorcliResponse(out)
+	if err != nil {
+		return nil, err
+	}
+	controllers := []*megaraidController{}
+	for _, c := range response.Controllers {
+		if c.CommandStatus.Status != "Success" {
+			log.Printf("storcli: controller %d: %s", c.CommandStatus.Controller, c.CommandStatus.Description)
+			continue
+		}
+		controller := &megaraidController{}
+		if err := json.Unmarshal(c.ResponseData, controller); err != nil {
+			return nil, err
+		}
+		controller.Basics.Controller = c.CommandStatus.Controller
+		controllers = append(controllers, controller)
+	}
+	return controllers, nil
+}
+
+// parseStorcliPatrolRead parses the output of storcli /call show patrolread J
+// into the current patrol read state of each controller.
+func parseStorcliPatrolRead(out string) (map[int]string, error) {
+	response, err := parseStorcliResponse(out)
+	if err != nil {
+		return nil, err
+	}
+	states := map[int]string{}
+	for _, c := range response.Controllers {
+		data := struct {
+			Properties []struct {
+				Name  string `json:"Ctrl_Prop"`
+				Value string `json:"Value"`
+			} `json:"Controller Properties"`
+		}{}
+		if err := json.Unmarshal(c.ResponseData, &data); err != nil {
+			continue
+		}
+		for _, prop := range data.Properties {
+			if prop.Name == "PR Current State" {
+				states[c.CommandStatus.Controller] = prop.Value
+			}
+		}
+	}
+	return states, nil
+}
+
+// parseStorcliPDStates parses the output of storcli /call/eall/sall show all J
+// into the state of each physical drive, by controller and device ID.
+func parseStorcliPDStates(out string) (map[int]map[int]*megaraidPDState, error) {
+	response, err := parseStorcliResponse(out)
+	if err != nil {
+		return nil, err
+	}
+	states := map[int]map[int]*megaraidPDState{}
+	for _, c := range response.Controllers {
+		sections := map[string]json.RawMessage{}
+		if err := json.Unmarshal(c.ResponseData, &sections); err != nil {
+			continue
+		}
+		controller := map[int]*megaraidPDState{}
+		for name, section := range sections {
+			// e.g. "Drive /c0/e32/s0", next to "Drive /c0/e32/s0 - Detailed Information"
+			if strings.Contains(name, " - ") {
+				continue
+			}
+			pds := []*megaraidPD{}
+			if err := json.Unmarshal(section, &pds); err != nil || len(pds) != 1 {
+				continue
+			}
+			details := map[string]json.RawMessage{}
+			if err := json.Unmarshal(sections[name+" - Detailed Information"], &details); err != nil {
+				continue
+			}
+			state := &megaraidPDState{}
+			if err := json.Unmarshal(details[name+" State"], state); err != nil {
+				continue
+			}
+			controller[pds[0].DID] = state
+		}
+		states[c.CommandStatus.Controller] = controller
+	}
+	return states, nil
+}
+
+// collectMegaRAID gathers the controllers, virtual and physical drives known
+// to the given storcli or perccli binary.
+func collectMegaRAID(run runner, storcli string) ([]*megaraidController, error) {
+	stdOut, _, err := run(storcli, "/call", "show", "all", "J")
+	if err != nil && stdOut == "" {
+		return nil, err
+	}
+	controllers, err := parseStorcliControllers(stdOut)
+	if err != nil {
+		return nil, err
+	}
+
+	stdOut, _, err = run(storcli, "/call", "show", "patrolread", "J")
+	if err != nil {
+		log.Println(err)
+	}
+	patrolRead, err := parseStorcliPatrolRead(stdOut)
+	if err != nil {
+		log.Println(err)
+	}
+
+	stdOut, _, err = run(storcli, "/call/eall/sall", "show", "all", "J")
+	if err != nil {
+		log.Println(err)
+	}
+	pdStates, err := parseStorcliPDStates(stdOut)
+	if err != nil {
+		log.Println(err)
+	}
+
+	hosts := scsiHostDevices("/sys/class/scsi_host")
+	for _, controller := range controllers {
+		controller.Device = hosts[storcliPCIAddress(controller.Basics.PCIAddress)]
+		controller.PatrolRead = patrolRead[controller.Basics.Controller]
+		for _, pd := range controller.PDs {
+			if state := pdStates[controller.Basics.Controller][pd.DID]; state != nil {
+				pd.MediaErrors = state.MediaErrors
+				pd.OtherErrors = state.OtherErrors
+				pd.PredictiveFailure = state.PredictiveFailure
+				pd.SMARTAlert = state.SMARTAlert
+			}
+		}
+	}
+	return controllers, nil
+}
+
+// diskGroup returns the disk group of the drive: a number, "F" for drives of
+// a foreign configuration, or "-" for drives in none.
+func (pd *megaraidPD) diskGroup() string {
+	return strings.Trim(string(pd.DG), `"`)
+}
+
+// storcliPCIAddress converts storcli's PCI address, domain:bus:device:function,
+// to the notation of sysfs.
+func storcliPCIAddress(address string) string {
+	parts := strings.Split(address, ":")
+	if len(parts) != 4 {
+		return ""
+	}
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseUint(part, 16, 16)
+		if err != nil {
+			return ""
+		}
+		values[i] = value
+	}
+	return fmt.Sprintf("%04x:%02x:%02x.%x", values[0], values[1], values[2], values[3])
+}
+
+var sysSCSIHostRgx = regexp.MustCompile(`/([0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7])/host(\d+)/`)
+
+// scsiHostDevices maps the PCI address of each SCSI host found under root,
+// usually /sys/class/scsi_host, to the /dev/bus/N path smartctl uses for it.
+func scsiHostDevices(root string) map[string]string {
+	devices := map[string]string{}
+	hosts, err := ioutil.ReadDir(root)
+	if err != nil {
+		return devices
+	}
+	for _, host := range hosts {
+		link, err := os.Readlink(filepath.Join(root, host.Name()))
+		if err != nil {
+			continue
+		}
+		if m := sysSCSIHostRgx.FindStringSubmatch(link); m != nil {
+			devices[m[1]] = "/dev/bus/" + m[2]
+		}
+	}
+	return devices
+}
+
+// findMegaRAIDDisk returns the scanned device addressing the physical drive
+// with the given device ID through -d megaraid,N on the given controller
+// device, or on any device if it is empty.
+func findMegaRAIDDisk(devices []deviceInfo, controller string, did int) *deviceInfo {
+	suffix := "megaraid," + strconv.Itoa(did)
+	for i := range devices {
+		if controller != "" && devices[i].Path != controller {
+			continue
+		}
+		if devices[i].Type == suffix || strings.HasSuffix(devices[i].Type, "+"+suffix) {
+			return &devices[i]
+		}
+	}
+	return nil
+}
+
+func megaraidPoints(controllers []*megaraidController, devices []deviceInfo, hostname string) []point {
+	points := []point{}
+	for _, controller := range controllers {
+		ctrl := strconv.Itoa(controller.Basics.Controller)
+		degraded, foreign := 0, 0
+		for _, vd := range controller.VDs {
+			if vd.State != "Optl" {
+				degraded++
+			}
+		}
+		for _, pd := range controller.PDs {
+			if pd.diskGroup() == "F" {
+				foreign++
+			}
+		}
+		fields := []string{
+			field("model", controller.Basics.Model),
+			field("serial", controller.Basics.SerialNumber),
+			field("firmware", controller.Version.FirmwareVersion),
+			field("status", controller.Status.ControllerStatus),
+			field("memory_correctable_errors", controller.Status.MemoryCorrectableErrors),
+			field("memory_uncorrectable_errors", controller.Status.MemoryUncorrectableErrors),
+			field("vds", len(controller.VDs)),
+			field("vds_not_optimal", degraded),
+			field("pds", len(controller.PDs)),
+			field("foreign_pds", foreign),
+		}
+		if controller.PatrolRead != "" {
+			fields = append(fields, field("patrol_read", controller.PatrolRead))
+		}
+		for _, battery := range append(controller.CacheVault, controller.BBU...) {
+			fields = append(fields,
+				field("battery_model", battery.Model),
+				field("battery_state", battery.State),
+				field("battery_optimal", battery.State == "Optimal"))
+		}
+		points = append(points, point{
+			Measurement: *checkName + "_megaraid_controller",
+			Tags:        []string{tag("host", hostname), tag("controller", ctrl)},
+			Fields:      fields,
+		})
+
+		for _, vd := range controller.VDs {
+			points = append(points, point{
+				Measurement: *checkName + "_megaraid_vd",
+				Tags:        []string{tag("host", hostname), tag("controller", ctrl), tag("vd", vd.DGVD)},
+				Fields: []string{
+					field("name", vd.Name),
+					field("raid_type", vd.Type),
+					field("state", vd.State),
+					field("optimal", vd.State == "Optl"),
+					field("access", vd.Access),
+					field("consistent", vd.Consist == "Yes"),
+					field("cache", vd.Cache),
+					field("size", vd.Size),
+				},
+			})
+		}
+
+		for _, pd := range controller.PDs {
+			tags := []string{tag("host", hostname), tag("controller", ctrl), tag("slot", pd.EIDSlot)}
+			// device IDs are only unique per controller, so on hosts with
+			// several, drives are only matched once their controller's is known
+			var disk *deviceInfo
+			if controller.Device != "" || len(controllers) == 1 {
+				disk = findMegaRAIDDisk(devices, controller.Device, pd.DID)
+			}
+			if disk != nil {
+				tags = append(tags, tag("disk", disk.Path), tag("type", strings.Replace(disk.Type, ",", "_", -1)))
+			} else {
+				tags = append(tags, tag("type", fmt.Sprintf("megaraid_%d", pd.DID)))
+			}
+			points = append(points, point{
+				Measurement: *checkName + "_megaraid_pd",
+				Tags:        tags,
+				Fields: []string{
+					field("did", pd.DID),
+					field("state", pd.State),
+					field("dg", pd.diskGroup()),
+					field("model", strings.TrimSpace(pd.Model)),
+					field("interface", pd.Intf),
+					field("medium", pd.Med),
+					field("size", pd.Size),
+					field("media_errors", pd.MediaErrors),
+					field("other_errors", pd.OtherErrors),
+					field("predictive_failures", pd.PredictiveFailure),
+					field("smart_alert", pd.SMARTAlert == "Yes"),
+				},
+			})
+		}
+	}
+	return points
+}
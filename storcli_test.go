@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var storcliShowAllOutput = `
+{
+"Controllers":[
+{
+	"Command Status" : {
+		"CLI Version" : "007.0709.0000.0000 Aug 14, 2018",
+		"Operating system" : "Linux 4.15.0-96-generic",
+		"Controller" : 0,
+		"Status" : "Success",
+		"Description" : "None"
+	},
+	"Response Data" : {
+		"Basics" : {
+			"Controller" : 0,
+			"Model" : "PERC H730P Mini",
+			"Serial Number" : "5AC01WV",
+			"Current Controller Date/Time" : "06/27/2017, 11:37:47",
+			"PCI Address" : "00:02:00:00"
+		},
+		"Version" : {
+			"Firmware Package Build" : "25.5.5.0005",
+			"Firmware Version" : "4.300.00-8352",
+			"Driver Name" : "megaraid_sas"
+		},
+		"Status" : {
+			"Controller Status" : "Needs Attention",
+			"Memory Correctable Errors" : 2,
+			"Memory Uncorrectable Errors" : 0,
+			"ECC Bucket Count" : 0,
+			"Any Offline VD Cache Preserved" : "No",
+			"BBU Status" : 0
+		},
+		"Virtual Drives" : 2,
+		"VD LIST" : [
+			{
+				"DG/VD" : "0/0",
+				"TYPE" : "RAID1",
+				"State" : "Optl",
+				"Access" : "RW",
+				"Consist" : "Yes",
+				"Cache" : "RWBD",
+				"Cac" : "-",
+				"sCC" : "ON",
+				"Size" : "278.875 GB",
+				"Name" : "system"
+			},
+			{
+				"DG/VD" : "1/1",
+				"TYPE" : "RAID5",
+				"State" : "Dgrd",
+				"Access" : "RW",
+				"Consist" : "No",
+				"Cache" : "RWBD",
+				"Cac" : "-",
+				"sCC" : "ON",
+				"Size" : "7.276 TB",
+				"Name" : "data"
+			}
+		],
+		"Physical Drives" : 3,
+		"PD LIST" : [
+			{
+				"EID:Slt" : "32:0",
+				"DID" : 0,
+				"State" : "Onln",
+				"DG" : 0,
+				"Size" : "278.875 GB",
+				"Intf" : "SAS",
+				"Med" : "HDD",
+				"SED" : "N",
+				"PI" : "N",
+				"SeSz" : "512B",
+				"Model" : "ST300MM0008     ",
+				"Sp" : "U",
+				"Type" : "-"
+			},
+			{
+				"EID:Slt" : "32:1",
+				"DID" : 1,
+				"State" : "Onln",
+				"DG" : 1,
+				"Size" : "3.638 TB",
+				"Intf" : "SATA",
+				"Med" : "HDD",
+				"SED" : "N",
+				"PI" : "N",
+				"SeSz" : "512B",
+				"Model" : "HGST HDN724040ALE640",
+				"Sp" : "U",
+				"Type" : "-"
+			},
+			{
+				"EID:Slt" : "32:2",
+				"DID" : 2,
+				"State" : "UGood",
+				"DG" : "F",
+				"Size" : "3.638 TB",
+				"Intf" : "SATA",
+				"Med" : "HDD",
+				"SED" : "N",
+				"PI" : "N",
+				"SeSz" : "512B",
+				"Model" : "HGST HDN724040ALE640",
+				"Sp" : "U",
+				"Type" : "-"
+			}
+		],
+		"Cachevault_Info" : [
+			{
+				"Model" : "CVPM02",
+				"State" : "Optimal",
+				"Temp" : "28C",
+				"Mode" : "-",
+				"MfgDate" : "2015/06/11"
+			}
+		]
+	}
+}
+]
+}
+`
+
+var storcliPatrolReadOutput = `
+{
+"Controllers":[
+{
+	"Command Status" : {
+		"Controller" : 0,
+		"Status" : "Success",
+		"Description" : "None"
+	},
+	"Response Data" : {
+		"Controller Properties" : [
+			{
+				"Ctrl_Prop" : "PR Mode",
+				"Value" : "Auto"
+			},
+			{
+				"Ctrl_Prop" : "PR Execution Delay",
+				"Value" : "168 hours"
+			},
+			{
+				"Ctrl_Prop" : "PR Current State",
+				"Value" : "Stopped"
+			}
+		]
+	}
+}
+]
+}
+`
+
+var storcliPDShowAllOutput = `
+{
+"Controllers":[
+{
+	"Command Status" : {
+		"Controller" : 0,
+		"Status" : "Success",
+		"Description" : "Show Drive Information Succeeded."
+	},
+	"Response Data" : {
+		"Drive /c0/e32/s0" : [
+			{
+				"EID:Slt" : "32:0",
+				"DID" : 0,
+				"State" : "Onln",
+				"DG" : 0,
+				"Size" : "278.875 GB",
+				"Intf" : "SAS",
+				"Med" : "HDD",
+				"Model" : "ST300MM0008     "
+			}
+		],
+		"Drive /c0/e32/s0 - Detailed Information" : {
+			"Drive /c0/e32/s0 State" : {
+				"Shield Counter" : 0,
+				"Media Error Count" : 0,
+				"Other Error Count" : 0,
+				"Drive Temperature" : " 33C (91.40 F)",
+				"Predictive Failure Count" : 0,
+				"S.M.A.R.T alert flagged by drive" : "No"
+			},
+			"Drive /c0/e32/s0 Device attributes" : {
+				"SN" : "S0K2ABCD",
+				"Firmware Revision" : "LS0A"
+			}
+		},
+		"Drive /c0/e32/s1" : [
+			{
+				"EID:Slt" : "32:1",
+				"DID" : 1,
+				"State" : "Onln",
+				"DG" : 1,
+				"Size" : "3.638 TB",
+				"Intf" : "SATA",
+				"Med" : "HDD",
+				"Model" : "HGST HDN724040ALE640"
+			}
+		],
+		"Drive /c0/e32/s1 - Detailed Information" : {
+			"Drive /c0/e32/s1 State" : {
+				"Shield Counter" : 0,
+				"Media Error Count" : 14,
+				"Other Error Count" : 3,
+				"Drive Temperature" : " 38C (100.40 F)",
+				"Predictive Failure Count" : 1,
+				"S.M.A.R.T alert flagged by drive" : "Yes"
+			}
+		}
+	}
+}
+]
+}
+`
+
+func TestParseStorcliControllers(t *testing.T) {
+	controllers, err := parseStorcliControllers(storcliShowAllOutput)
+	assert.NoError(t, err)
+	assert.Len(t, controllers, 1)
+	c := controllers[0]
+	assert.Equal(t, 0, c.Basics.Controller)
+	assert.Equal(t, "PERC H730P Mini", c.Basics.Model)
+	assert.Equal(t, "4.300.00-8352", c.Version.FirmwareVersion)
+	assert.Equal(t, "Needs Attention", c.Status.ControllerStatus)
+	assert.Equal(t, 2, c.Status.MemoryCorrectableErrors)
+	assert.Len(t, c.VDs, 2)
+	assert.Equal(t, "Dgrd", c.VDs[1].State)
+	assert.Len(t, c.PDs, 3)
+	assert.Equal(t, "0", c.PDs[0].diskGroup())
+	assert.Equal(t, "F", c.PDs[2].diskGroup())
+	assert.Equal(t, "Optimal", c.CacheVault[0].State)
+
+	_, err = parseStorcliControllers("")
+	assert.Error(t, err)
+}
+
+func TestCollectMegaRAID(t *testing.T) {
+	outputs := map[string]string{
+		"/call show all J":           storcliShowAllOutput,
+		"/call show patrolread J":    storcliPatrolReadOutput,
+		"/call/eall/sall show all J": storcliPDShowAllOutput,
+	}
+	fake := func(name string, args ...string) (string, string, error) {
+		assert.Equal(t, "/opt/MegaRAID/perccli/perccli64", name)
+		if out, ok := outputs[strings.Join(args, " ")]; ok {
+			return out, "", nil
+		}
+		return "", "", errors.New("unexpected command")
+	}
+
+	controllers, err := collectMegaRAID(fake, "/opt/MegaRAID/perccli/perccli64")
+	assert.NoError(t, err)
+	assert.Len(t, controllers, 1)
+	assert.Equal(t, "Stopped", controllers[0].PatrolRead)
+	assert.Equal(t, 14, controllers[0].PDs[1].MediaErrors)
+	assert.Equal(t, 3, controllers[0].PDs[1].OtherErrors)
+	assert.Equal(t, 1, controllers[0].PDs[1].PredictiveFailure)
+	assert.Equal(t, "Yes", controllers[0].PDs[1].SMARTAlert)
+
+	devices := parseSMARTCtlScan(`/dev/bus/0 -d megaraid,0 # /dev/bus/0 [megaraid_disk_00], SCSI device
+/dev/bus/0 -d sat+megaraid,1 # /dev/bus/0 [megaraid_disk_01], SCSI device`)
+	points := megaraidPoints(controllers, devices, "db01")
+	assert.Len(t, points, 6)
+	assert.Equal(t, []string{"host=db01", "controller=0"}, points[0].Tags)
+	assert.Contains(t, points[0].Fields, "vds_not_optimal=1")
+	assert.Contains(t, points[0].Fields, "foreign_pds=1")
+	assert.Contains(t, points[0].Fields, `patrol_read="Stopped"`)
+	assert.Contains(t, points[0].Fields, "battery_optimal=true")
+	assert.Contains(t, points[2].Fields, "optimal=false")
+	assert.Equal(t, []string{"host=db01", "controller=0", "slot=32:1", "disk=/dev/bus/0", "type=sat+megaraid_1"}, points[4].Tags)
+	assert.Contains(t, points[4].Fields, "media_errors=14")
+	assert.Contains(t, points[4].Fields, "smart_alert=true")
+	assert.Equal(t, []string{"host=db01", "controller=0", "slot=32:2", "type=megaraid_2"}, points[5].Tags)
+}
+
+func TestMegaRAIDPointsSeveralControllers(t *testing.T) {
+	controllers := []*megaraidController{{Device: "/dev/bus/0"}, {Device: "/dev/bus/6"}, {}}
+	controllers[1].Basics.Controller = 1
+	controllers[2].Basics.Controller = 2
+	for _, controller := range controllers {
+		controller.PDs = []*megaraidPD{{EIDSlot: "32:0", DID: 0}}
+	}
+	devices := parseSMARTCtlScan(`/dev/bus/0 -d megaraid,0 # /dev/bus/0 [megaraid_disk_00], SCSI device
+/dev/bus/6 -d sat+megaraid,0 # /dev/bus/6 [megaraid_disk_00], SCSI device`)
+
+	points := megaraidPoints(controllers, devices, "db01")
+	assert.Len(t, points, 6)
+	assert.Equal(t, []string{"host=db01", "controller=0", "slot=32:0", "disk=/dev/bus/0", "type=megaraid_0"}, points[1].Tags)
+	assert.Equal(t, []string{"host=db01", "controller=1", "slot=32:0", "disk=/dev/bus/6", "type=sat+megaraid_0"}, points[3].Tags)
+	assert.Equal(t, []string{"host=db01", "controller=2", "slot=32:0", "type=megaraid_0"}, points[5].Tags)
+}
+
+func TestSCSIHostDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk-health-checker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Symlink("../../devices/pci0000:00/0000:00:02.0/0000:02:00.0/host0/scsi_host/host0", filepath.Join(dir, "host0")))
+	assert.NoError(t, os.Symlink("../../devices/pci0000:80/0000:80:03.0/0000:83:00.0/host6/scsi_host/host6", filepath.Join(dir, "host6")))
+	assert.NoError(t, os.Symlink("../../devices/platform/host2/scsi_host/host2", filepath.Join(dir, "host2")))
+
+	assert.Equal(t, map[string]string{"0000:02:00.0": "/dev/bus/0", "0000:83:00.0": "/dev/bus/6"}, scsiHostDevices(dir))
+	assert.Equal(t, "0000:83:00.0", storcliPCIAddress("00:83:00:00"))
+	assert.Equal(t, "", storcliPCIAddress("n/a"))
+}
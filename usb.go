@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// usbBridgeTypes are the smartctl device types tried, in order, to get SMART
+// data through the USB to ATA bridge of an external enclosure.
+var usbBridgeTypes = []string{"sat", "sat,12", "usbjmicron", "usbprolific", "usbcypress", "usbsunplus"}
+
+// isUSBDisk tells whether the disk with the given kernel name is attached
+// through USB, using the sysfs device hierarchy.
+func isUSBDisk(name string) bool {
+	if name == "" {
+		return false
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join("/sys/block", name, "device"))
+	return err == nil && strings.Contains(path, "/usb")
+}
+
+// negotiateBridgeType tries the known USB bridge device types until smartctl
+// reports SMART support through one of them. It returns that type and what
+// smartctl reported, or an empty type if none worked.
+func negotiateBridgeType(run runner, smartctlPath string, device deviceInfo) (string, *smartCtlInfo) {
+	for _, deviceType := range usbBridgeTypes {
+		if deviceType == device.Type {
+			continue
+		}
+		bridged := device
+		bridged.Type = deviceType
+		stdOut, _, _ := run(smartctlPath, bridged.args("-i", "-H")...)
+		if info := parseSMARTCtlInfo(stdOut); info.SMARTSupport {
+			return deviceType, info
+		}
+	}
+	return "", nil
+}
+
+// readUSBDisk runs smartctl with the given options on a USB disk. The bridge
+// type that worked for the disk last seen at the device's path is tried
+// first, to read it in a single run, then the scanned type and finally every
+// known bridge type. It returns the device with the type read through,
+// smartctl's output and the bridge type, empty if the scanned type worked.
+func readUSBDisk(run runner, smartctlPath string, state *stateStore, device deviceInfo, options []string) (deviceInfo, string, string) {
+	read := func(device deviceInfo) (string, *smartCtlInfo) {
+		stdOut, _, err := run(smartctlPath, device.args(options...)...)
+		if err != nil {
+			log.Println(err)
+		}
+		sections := splitSmartctlSections(stdOut)
+		return stdOut, parseSMARTCtlInfo(sections["info"] + sections["health"])
+	}
+
+	scanned := device.Type
+	var lastSerial string
+	if state.get("usb_serial:"+device.Path, &lastSerial) {
+		state.get("usb_bridge:"+lastSerial, &device.Type)
+	}
+	stdOut, info := read(device)
+	if device.Type != scanned && (!info.SMARTSupport || info.SerialNumber != lastSerial) {
+		// another disk, or another enclosure, is there now
+		device.Type = scanned
+		stdOut, info = read(device)
+	}
+	if !info.SMARTSupport {
+		if deviceType, bridgeInfo := negotiateBridgeType(run, smartctlPath, device); bridgeInfo != nil {
+			device.Type = deviceType
+			stdOut, info = read(device)
+		}
+	}
+	if device.Type == scanned {
+		return device, stdOut, ""
+	}
+	// without a serial, there is nothing to tell the disk by
+	if info.SerialNumber != "" {
+		state.set("usb_bridge:"+info.SerialNumber, device.Type)
+		state.set("usb_serial:"+device.Path, info.SerialNumber)
+	}
+	return device, stdOut, device.Type
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var usbUnsupportedInfoOutput = `
+=== START OF INFORMATION SECTION ===
+Vendor:               JMicron
+Product:              Generic
+Revision:             0508
+User Capacity:        2,000,398,934,016 bytes [2.00 TB]
+Logical block size:   512 bytes
+Device type:          disk
+Local Time is:        Tue Jun 27 11:37:47 2017 CEST
+SMART support is:     Unavailable - device lacks SMART capability.
+`
+
+var usbSATInfoOutput = `
+=== START OF INFORMATION SECTION ===
+Device Model:     WDC WD20EARX-00PASB0
+Serial Number:    WD-WMAZA1234567
+Firmware Version: 51.0AB51
+User Capacity:    2,000,398,934,016 bytes [2.00 TB]
+Sector Sizes:     512 bytes logical, 4096 bytes physical
+ATA Version is:   ATA8-ACS (minor revision not indicated)
+SMART support is: Available - device has SMART capability.
+SMART support is: Enabled
+
+=== START OF READ SMART DATA SECTION ===
+SMART overall-health self-assessment test result: PASSED
+`
+
+func TestNegotiateBridgeType(t *testing.T) {
+	tried := []string{}
+	run := func(name string, args ...string) (string, string, error) {
+		tried = append(tried, args[len(args)-1])
+		if strings.Join(args, " ") == "-i -H /dev/sdc -d usbjmicron" {
+			return usbSATInfoOutput, "", nil
+		}
+		return usbUnsupportedInfoOutput, "", nil
+	}
+	device := deviceInfo{Path: "/dev/sdc", Type: "auto"}
+
+	deviceType, info := negotiateBridgeType(run, "/usr/sbin/smartctl", device)
+	assert.Equal(t, "usbjmicron", deviceType)
+	assert.Equal(t, "WD-WMAZA1234567", info.SerialNumber)
+	assert.Equal(t, []string{"sat", "sat,12", "usbjmicron"}, tried)
+
+	tried = tried[:0]
+	deviceType, info = negotiateBridgeType(run, "/usr/sbin/smartctl", deviceInfo{Path: "/dev/sdd", Type: "sat"})
+	assert.Equal(t, "", deviceType)
+	assert.Nil(t, info)
+	assert.Equal(t, []string{"sat,12", "usbjmicron", "usbprolific", "usbcypress", "usbsunplus"}, tried)
+}
+
+var usbOtherSATInfoOutput = strings.Replace(usbSATInfoOutput, "WD-WMAZA1234567", "WD-WCC4M7654321", 1)
+
+var usbNoSerialInfoOutput = strings.Replace(usbSATInfoOutput, "Serial Number:    WD-WMAZA1234567\n", "", 1)
+
+// usbRunner answers with the output given for each device type, and with
+// usbUnsupportedInfoOutput for the others, recording the types run with.
+func usbRunner(outputs map[string]string, tried *[]string) runner {
+	return func(name string, args ...string) (string, string, error) {
+		deviceType := args[len(args)-1]
+		*tried = append(*tried, deviceType)
+		if out, ok := outputs[deviceType]; ok {
+			return out, "", nil
+		}
+		return usbUnsupportedInfoOutput, "", nil
+	}
+}
+
+func TestReadUSBDisk(t *testing.T) {
+	state := &stateStore{Entries: map[string]json.RawMessage{}}
+	device := deviceInfo{Path: "/dev/sdc", Type: "auto"}
+	options := []string{"-i", "-H", "-A"}
+	tried := []string{}
+	run := usbRunner(map[string]string{"usbjmicron": usbSATInfoOutput}, &tried)
+
+	read, stdOut, bridgeType := readUSBDisk(run, "/usr/sbin/smartctl", state, device, options)
+	assert.Equal(t, "usbjmicron", read.Type)
+	assert.Equal(t, "usbjmicron", bridgeType)
+	assert.Equal(t, usbSATInfoOutput, stdOut)
+	assert.Equal(t, []string{"auto", "sat", "sat,12", "usbjmicron", "usbjmicron"}, tried)
+	var remembered string
+	assert.True(t, state.get("usb_bridge:WD-WMAZA1234567", &remembered))
+	assert.Equal(t, "usbjmicron", remembered)
+
+	// the type that worked last time is tried first
+	tried = tried[:0]
+	read, _, bridgeType = readUSBDisk(run, "/usr/sbin/smartctl", state, device, options)
+	assert.Equal(t, "usbjmicron", read.Type)
+	assert.Equal(t, "usbjmicron", bridgeType)
+	assert.Equal(t, []string{"usbjmicron"}, tried)
+
+	// the enclosure was replaced by one with another bridge
+	tried = tried[:0]
+	run = usbRunner(map[string]string{"sat": usbSATInfoOutput}, &tried)
+	read, _, bridgeType = readUSBDisk(run, "/usr/sbin/smartctl", state, device, options)
+	assert.Equal(t, "sat", read.Type)
+	assert.Equal(t, "sat", bridgeType)
+	assert.Equal(t, []string{"usbjmicron", "auto", "sat", "sat"}, tried)
+	assert.True(t, state.get("usb_bridge:WD-WMAZA1234567", &remembered))
+	assert.Equal(t, "sat", remembered)
+
+	// another disk is at the same path, read through the scanned type
+	tried = tried[:0]
+	run = usbRunner(map[string]string{"auto": usbOtherSATInfoOutput, "sat": usbOtherSATInfoOutput}, &tried)
+	read, stdOut, bridgeType = readUSBDisk(run, "/usr/sbin/smartctl", state, device, options)
+	assert.Equal(t, "auto", read.Type)
+	assert.Equal(t, "", bridgeType)
+	assert.Equal(t, usbOtherSATInfoOutput, stdOut)
+	assert.Equal(t, []string{"sat", "auto"}, tried)
+}
+
+func TestReadUSBDiskWithoutSerial(t *testing.T) {
+	state := &stateStore{Entries: map[string]json.RawMessage{}}
+	device := deviceInfo{Path: "/dev/sdc", Type: "auto"}
+	tried := []string{}
+	run := usbRunner(map[string]string{"sat,12": usbNoSerialInfoOutput}, &tried)
+
+	read, _, bridgeType := readUSBDisk(run, "/usr/sbin/smartctl", state, device, []string{"-i", "-H"})
+	assert.Equal(t, "sat,12", read.Type)
+	assert.Equal(t, "sat,12", bridgeType)
+	assert.Empty(t, state.Entries)
+
+	// so it is negotiated again on every run
+	tried = tried[:0]
+	readUSBDisk(run, "/usr/sbin/smartctl", state, device, []string{"-i", "-H"})
+	assert.Equal(t, []string{"auto", "sat", "sat,12", "sat,12"}, tried)
+}
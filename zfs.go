@@ -0,0 +1,174 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type zpoolVDev struct {
+	Name   string
+	State  string
+	Read   int64
+	Write  int64
+	Cksum  int64
+	Notes  string
+	Parent string
+	Depth  int
+	Leaf   bool
+}
+
+type zpoolStatus struct {
+	Name       string
+	State      string
+	Read       int64
+	Write      int64
+	Cksum      int64
+	Errors     string
+	DataErrors int64
+	VDevs      []*zpoolVDev
+}
+
+var zpoolDataErrorsRgx = regexp.MustCompile(`^(\d+) data errors`)
+
+func parseZpoolStatus(out string) []*zpoolStatus {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	pools := []*zpoolStatus{}
+	var pool *zpoolStatus
+	var inConfig bool
+	var indentPrefix string
+	var parents []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		sliced := strings.SplitN(trimmed, ":", 2)
+		switch {
+		case sliced[0] == "pool" && len(sliced) == 2:
+			pool = &zpoolStatus{Name: strings.TrimSpace(sliced[1])}
+			pools = append(pools, pool)
+			inConfig = false
+			continue
+		case pool == nil:
+			continue
+		case sliced[0] == "state" && len(sliced) == 2:
+			pool.State = strings.TrimSpace(sliced[1])
+			continue
+		case sliced[0] == "errors" && len(sliced) == 2:
+			pool.Errors = strings.TrimSpace(sliced[1])
+			if m := zpoolDataErrorsRgx.FindStringSubmatch(pool.Errors); m != nil {
+				pool.DataErrors, _ = strconv.ParseInt(m[1], 10, 64)
+			}
+			inConfig = false
+			continue
+		case strings.HasPrefix(trimmed, "NAME") && strings.HasSuffix(trimmed, "CKSUM"):
+			inConfig = true
+			indentPrefix = line[:strings.Index(line, "NAME")]
+			parents = parents[:0]
+			continue
+		}
+
+		if !inConfig || trimmed == "" {
+			continue
+		}
+
+		indent := strings.TrimPrefix(line, indentPrefix)
+		depth := (len(indent) - len(strings.TrimLeft(indent, " "))) / 2
+		columns := strings.Fields(trimmed)
+		if depth < len(parents) {
+			parents = parents[:depth]
+		}
+		for len(parents) < depth {
+			parents = append(parents, "")
+		}
+		parents = append(parents, columns[0])
+
+		if depth == 0 && columns[0] == pool.Name {
+			if len(columns) >= 5 {
+				pool.Read, _ = strconv.ParseInt(columns[2], 10, 64)
+				pool.Write, _ = strconv.ParseInt(columns[3], 10, 64)
+				pool.Cksum, _ = strconv.ParseInt(columns[4], 10, 64)
+			}
+			continue
+		}
+
+		// class headers such as "logs", "cache" or "spares" carry no state
+		if len(columns) < 2 {
+			continue
+		}
+
+		vdev := &zpoolVDev{Name: columns[0], State: columns[1], Depth: depth}
+		if depth > 0 {
+			vdev.Parent = parents[depth-1]
+		}
+		if len(columns) >= 5 {
+			vdev.Read, _ = strconv.ParseInt(columns[2], 10, 64)
+			vdev.Write, _ = strconv.ParseInt(columns[3], 10, 64)
+			vdev.Cksum, _ = strconv.ParseInt(columns[4], 10, 64)
+			vdev.Notes = strings.Join(columns[5:], " ")
+		}
+		pool.VDevs = append(pool.VDevs, vdev)
+	}
+
+	for _, pool := range pools {
+		for i, vdev := range pool.VDevs {
+			vdev.Leaf = i == len(pool.VDevs)-1 || pool.VDevs[i+1].Depth <= vdev.Depth
+		}
+	}
+
+	return pools
+}
+
+// zpoolArrays maps the path of every scanned disk holding a leaf vdev to the
+// pool and vdev it is a member of.
+func zpoolArrays(pools []*zpoolStatus, devices []deviceInfo) map[string]string {
+	arrays := map[string]string{}
+	for _, pool := range pools {
+		for _, vdev := range pool.VDevs {
+			if !vdev.Leaf {
+				continue
+			}
+			if disk := findDisk(devices, vdev.Name); disk != nil {
+				arrays[disk.Path] = pool.Name + "/" + vdev.Parent
+			}
+		}
+	}
+	return arrays
+}
+
+func zpoolPoints(pools []*zpoolStatus, devices []deviceInfo, hostname string) []point {
+	points := []point{}
+	for _, pool := range pools {
+		points = append(points, point{
+			Measurement: *checkName + "_zpool",
+			Tags:        []string{tag("host", hostname), tag("pool", pool.Name)},
+			Fields: []string{
+				field("state", pool.State),
+				field("read_errors", pool.Read),
+				field("write_errors", pool.Write),
+				field("cksum_errors", pool.Cksum),
+				field("data_errors", pool.DataErrors),
+			},
+		})
+		for _, vdev := range pool.VDevs {
+			tags := []string{tag("host", hostname), tag("pool", pool.Name), tag("vdev", vdev.Name)}
+			if vdev.Parent != "" {
+				tags = append(tags, tag("parent", vdev.Parent))
+			}
+			if vdev.Leaf {
+				if disk := findDisk(devices, vdev.Name); disk != nil {
+					tags = append(tags, tag("disk", disk.Path))
+				}
+			}
+			points = append(points, point{
+				Measurement: *checkName + "_zpool_vdev",
+				Tags:        tags,
+				Fields: []string{
+					field("state", vdev.State),
+					field("read_errors", vdev.Read),
+					field("write_errors", vdev.Write),
+					field("cksum_errors", vdev.Cksum),
+				},
+			})
+		}
+	}
+	return points
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseZpoolStatus(t *testing.T) {
+	var zpoolStatusOutput = `
+  pool: rpool
+ state: ONLINE
+  scan: scrub repaired 0B in 0 days 00:04:12 with 0 errors on Sun Jun 11 00:28:13 2017
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	rpool       ONLINE       0     0     0
+	  mirror-0  ONLINE       0     0     0
+	    sda2    ONLINE       0     0     0
+	    sdb2    ONLINE       0     0     0
+
+errors: No known data errors
+
+  pool: tank
+ state: DEGRADED
+status: One or more devices are faulted in response to persistent errors.
+	Sufficient replicas exist for the pool to continue functioning in a
+	degraded state.
+action: Replace the faulted device, or use 'zpool clear' to mark the device
+	repaired.
+  scan: resilvered 1.50M in 0 days 00:00:01 with 0 errors on Tue Jun 27 10:00:03 2017
+config:
+
+	NAME                                          STATE     READ WRITE CKSUM
+	tank                                          DEGRADED     0     0     0
+	  raidz1-0                                    DEGRADED     0     0     0
+	    ata-HGST_HDN724040ALE640_PK2338P4H4XPXC   ONLINE       0     0     0
+	    ata-HGST_HDN724040ALE640_PK2338P4H4WKYC   FAULTED     12   164     0  too many errors
+	    ata-HGST_HDN724040ALE640_PK1334PBGZ0A3S   ONLINE       0     0     7
+	logs
+	  nvme0n1p1                                   ONLINE       0     0     0
+	spares
+	  ata-HGST_HDN724040ALE640_PK1334PBGZ1B4T     AVAIL
+
+errors: 3 data errors, use '-v' for a list
+`
+	pools := parseZpoolStatus(zpoolStatusOutput)
+	assert.Len(t, pools, 2)
+
+	assert.Equal(t, "rpool", pools[0].Name)
+	assert.Equal(t, "ONLINE", pools[0].State)
+	assert.Equal(t, "No known data errors", pools[0].Errors)
+	assert.Equal(t, int64(0), pools[0].DataErrors)
+	assert.Len(t, pools[0].VDevs, 3)
+	assert.Equal(t, "mirror-0", pools[0].VDevs[0].Name)
+	assert.Equal(t, "rpool", pools[0].VDevs[0].Parent)
+	assert.Equal(t, false, pools[0].VDevs[0].Leaf)
+	assert.Equal(t, "sdb2", pools[0].VDevs[2].Name)
+	assert.Equal(t, "mirror-0", pools[0].VDevs[2].Parent)
+	assert.Equal(t, true, pools[0].VDevs[2].Leaf)
+
+	assert.Equal(t, "tank", pools[1].Name)
+	assert.Equal(t, "DEGRADED", pools[1].State)
+	assert.Equal(t, int64(3), pools[1].DataErrors)
+	assert.Len(t, pools[1].VDevs, 6)
+	faulted := pools[1].VDevs[2]
+	assert.Equal(t, "ata-HGST_HDN724040ALE640_PK2338P4H4WKYC", faulted.Name)
+	assert.Equal(t, "FAULTED", faulted.State)
+	assert.Equal(t, int64(12), faulted.Read)
+	assert.Equal(t, int64(164), faulted.Write)
+	assert.Equal(t, int64(0), faulted.Cksum)
+	assert.Equal(t, "too many errors", faulted.Notes)
+	assert.Equal(t, "raidz1-0", faulted.Parent)
+	assert.Equal(t, true, faulted.Leaf)
+	assert.Equal(t, int64(7), pools[1].VDevs[3].Cksum)
+	assert.Equal(t, "nvme0n1p1", pools[1].VDevs[4].Name)
+	assert.Equal(t, "logs", pools[1].VDevs[4].Parent)
+	assert.Equal(t, true, pools[1].VDevs[4].Leaf)
+	assert.Equal(t, "AVAIL", pools[1].VDevs[5].State)
+	assert.Equal(t, "spares", pools[1].VDevs[5].Parent)
+}
+
+func TestParentDisk(t *testing.T) {
+	assert.Equal(t, "/dev/sda", parentDisk("/dev/sda1"))
+	assert.Equal(t, "/dev/sdab", parentDisk("/dev/sdab12"))
+	assert.Equal(t, "/dev/sda", parentDisk("/dev/sda"))
+	assert.Equal(t, "/dev/nvme0n1", parentDisk("/dev/nvme0n1p2"))
+	assert.Equal(t, "/dev/nvme0n1", parentDisk("/dev/nvme0n1"))
+	assert.Equal(t, "/dev/md0", parentDisk("/dev/md0"))
+}
+
+func TestDeviceKernelName(t *testing.T) {
+	assert.Equal(t, "null", deviceKernelName(deviceInfo{Path: "/dev/null", Type: "auto"}))
+	for _, deviceType := range []string{"megaraid,8", "sat+megaraid,0", "cciss,1", "aacraid,0,0,2"} {
+		assert.Equal(t, "", deviceKernelName(deviceInfo{Path: "/dev/null", Type: deviceType}), deviceType)
+	}
+}